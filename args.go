@@ -0,0 +1,147 @@
+package olive
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ToArgs renders the result back into a canonical list of arguments (excluding
+// the application name) which parses to the same result.  The flags and
+// arguments of each command, sorted by name, come right before its subcommand
+// so that they apply to the same command when they are parsed again even if
+// the subcommand has flags or arguments with the same names.  The primary
+// argument and any trailing arguments come last after a `--` terminator if
+// necessary.  Empty lists are omitted since they cannot be given.  This should
+// be called on the result of the initial command.
+func (apr *ArgParseResult) ToArgs() []string {
+	var args []string
+
+	sep := "="
+	if apr.command != nil {
+		sep = apr.command.root().assignSep
+	}
+
+	_, last := selectedCommand(apr)
+
+	for res := apr; res != nil; res = res.subcommandRes {
+		flags := make([]string, 0, len(res.flags)+len(res.negated))
+		for name := range res.flags {
+			flags = append(flags, name)
+		}
+
+		for name := range res.negated {
+			flags = append(flags, "no-"+name)
+		}
+		sort.Strings(flags)
+
+		for _, name := range flags {
+			if count := res.FlagCount(name); count > 1 {
+				args = append(args, "--"+name+sep+strconv.Itoa(count))
+			} else {
+				args = append(args, "--"+name)
+			}
+		}
+
+		names := make([]string, 0, len(res.Arguments))
+		for name := range res.Arguments {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			// integer lists can only be given by repeating the argument
+			if elems, ok := res.Arguments[name].([]int); ok {
+				for _, elem := range elems {
+					args = append(args, "--"+name+sep+strconv.Itoa(elem))
+				}
+
+				continue
+			}
+
+			if elems, ok := res.Arguments[name].([]string); ok && len(elems) == 0 {
+				continue
+			}
+
+			args = append(args, "--"+name+sep+res.formatArgValue(name))
+		}
+
+		if name, _, ok := res.Subcommand(); ok {
+			args = append(args, name)
+		}
+	}
+
+	// as literal values, the primary argument and trailing arguments can only
+	// be given safely after the terminator
+	primaryArgs := last.PrimaryArgs()
+	if len(primaryArgs) > 0 && len(last.trailingArgs) == 0 && !anyDashed(primaryArgs) {
+		args = append(args, primaryArgs...)
+	} else if len(primaryArgs) > 0 || len(last.trailingArgs) > 0 {
+		args = append(args, "--")
+		args = append(args, primaryArgs...)
+		args = append(args, last.trailingArgs...)
+	}
+
+	return args
+}
+
+// formatArgValue formats the value of the named argument of the result so that
+// it parses to the same value.  Values decoded by a codec are rendered as they
+// were given.
+func (apr *ArgParseResult) formatArgValue(name string) string {
+	if raw, ok := apr.rawValues[name]; ok {
+		return raw
+	}
+
+	val := apr.Arguments[name]
+
+	if apr.command != nil {
+		if ca, ok := apr.command.args[name].(*ChoiceArgument); ok {
+			if key, ok := ca.keyOf(val); ok {
+				return key
+			}
+		}
+	}
+
+	return formatValue(val)
+}
+
+// formatValue formats the value of an argument so that it parses to the same
+// value
+func formatValue(val interface{}) string {
+	switch v := val.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	case []string:
+		elems := make([]string, len(v))
+		for i, elem := range v {
+			elem = strings.ReplaceAll(elem, "\\", "\\\\")
+			elems[i] = strings.ReplaceAll(elem, ",", "\\,")
+		}
+
+		// a trailing separator does not produce an empty final element so an
+		// empty final element needs a separator of its own
+		if len(v) > 1 && v[len(v)-1] == "" {
+			return strings.Join(elems, ",") + ","
+		}
+
+		return strings.Join(elems, ",")
+	}
+
+	return fmt.Sprint(val)
+}
+
+// anyDashed checks whether any of the values begin with a dash and so would be
+// mistaken for flags or arguments
+func anyDashed(vals []string) bool {
+	for _, val := range vals {
+		if strings.HasPrefix(val, "-") {
+			return true
+		}
+	}
+
+	return false
+}
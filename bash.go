@@ -0,0 +1,107 @@
+package olive
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// GenerateBashCompletion generates a bash completion script for the CLI which
+// completes the names of subcommands and the long names of flags and arguments.
+// The flags and arguments of parent commands are completed for their
+// subcommands as well.  The script registers its completion function using
+// `complete -F` so it can be sourced directly (eg. from `.bashrc`).  Since the
+// script is static, the availability of subcommands set by `SetAvailableIf` is
+// not applied: all subcommands are completed.
+func GenerateBashCompletion(cli *Command) string {
+	fnName := "_" + bashIdentifier(cli.Name) + "_completions"
+
+	var transitions, completions strings.Builder
+	writeBashCompletions(&transitions, &completions, cli, cli.Name, nil)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s() {\n", fnName)
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(&b, "    local cmd=%s\n", bashQuote(cli.Name))
+	b.WriteString("    local i\n\n")
+
+	// the words before the cursor select the command being completed
+	b.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	b.WriteString("        case \"$cmd:${COMP_WORDS[i]}\" in\n")
+	b.WriteString(transitions.String())
+	b.WriteString("        esac\n")
+	b.WriteString("    done\n\n")
+
+	b.WriteString("    local words=\"\"\n")
+	b.WriteString("    case \"$cmd\" in\n")
+	b.WriteString(completions.String())
+	b.WriteString("    esac\n\n")
+
+	b.WriteString("    COMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	b.WriteString("}\n\n")
+
+	fmt.Fprintf(&b, "complete -F %s %s\n", fnName, cli.Name)
+	return b.String()
+}
+
+// writeBashCompletions writes the cases of the completion function for a
+// command and all of its subcommands.  `path` is the names of the commands
+// leading to the command separated by spaces and `inherited` is the names
+// inherited from its parent commands.
+func writeBashCompletions(transitions, completions *strings.Builder, c *Command, path string, inherited []string) {
+	names := append([]string(nil), inherited...)
+	seen := make(map[string]struct{})
+	for _, name := range names {
+		seen[name] = struct{}{}
+	}
+
+	addName := func(name string) {
+		if _, ok := seen["--"+name]; !ok {
+			seen["--"+name] = struct{}{}
+			names = append(names, "--"+name)
+		}
+	}
+
+	for _, flag := range c.Flags() {
+		if flag.Enabled() && !flag.hidden {
+			addName(flag.name)
+		}
+	}
+
+	for _, arg := range c.Arguments() {
+		if arg.Enabled() && !arg.base().hidden {
+			addName(arg.Name())
+		}
+	}
+
+	var words []string
+	for _, subc := range c.Subcommands() {
+		words = append(words, subc.Name)
+
+		subPath := path + " " + subc.Name
+		fmt.Fprintf(transitions, "            %s) cmd=%s ;;\n", bashQuote(path+":"+subc.Name), bashQuote(subPath))
+	}
+
+	words = append(words, names...)
+	fmt.Fprintf(completions, "        %s) words=%s ;;\n", bashQuote(path), bashQuote(strings.Join(words, " ")))
+
+	for _, subc := range c.Subcommands() {
+		writeBashCompletions(transitions, completions, subc, path+" "+subc.Name, names)
+	}
+}
+
+// bashIdentifier converts a name into a valid bash function name
+func bashIdentifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+
+		return '_'
+	}, name)
+}
+
+// bashQuote quotes a string for use as a single word in bash
+func bashQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
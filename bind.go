@@ -0,0 +1,399 @@
+package olive
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The bind functions provide an API similar to that of the standard library's
+// `flag` package: each one defines a flag or argument and writes its value into
+// the given variable after parsing succeeds.  A variable is left unchanged if
+// its flag or argument is not given a value so its initial value acts as the
+// default.
+
+// BindFlagVar adds a flag which sets the given variable to `true` when it is
+// passed
+func (c *Command) BindFlagVar(p *bool, name, shortName, desc string) *Flag {
+	flag := c.AddFlag(name, shortName, desc)
+
+	c.bindings = append(c.bindings, func(res *ArgParseResult) {
+		if res.HasFlag(name) {
+			*p = true
+		}
+	})
+
+	return flag
+}
+
+// BindIntVar adds a named integer argument whose value is stored in the given
+// variable
+func (c *Command) BindIntVar(p *int, name, shortName, desc string) *IntArgument {
+	ia := c.AddIntArg(name, shortName, desc, false)
+
+	c.bindings = append(c.bindings, func(res *ArgParseResult) {
+		if val, ok := res.Arguments[name]; ok {
+			*p = val.(int)
+		}
+	})
+
+	return ia
+}
+
+// BindFloatVar adds a named float argument whose value is stored in the given
+// variable
+func (c *Command) BindFloatVar(p *float64, name, shortName, desc string) *FloatArgument {
+	fa := c.AddFloatArg(name, shortName, desc, false)
+
+	c.bindings = append(c.bindings, func(res *ArgParseResult) {
+		if val, ok := res.Arguments[name]; ok {
+			*p = val.(float64)
+		}
+	})
+
+	return fa
+}
+
+// BindStringVar adds a named string argument whose value is stored in the given
+// variable
+func (c *Command) BindStringVar(p *string, name, shortName, desc string) *StringArgument {
+	sa := c.AddStringArg(name, shortName, desc, false)
+
+	c.bindings = append(c.bindings, func(res *ArgParseResult) {
+		if val, ok := res.Arguments[name]; ok {
+			*p = val.(string)
+		}
+	})
+
+	return sa
+}
+
+// BindStruct defines a flag or argument for every field of the struct pointed to
+// by `v` with an `olive` tag and writes the parsed values into the fields after
+// parsing succeeds.  The tag holds the name of the flag or argument followed by
+// its options separated by commas:
+//
+//	Jobs  int      `olive:"jobs,short=j,min=1,max=64,default=4" desc:"..."`
+//	Mode  string   `olive:"mode,short=m,required,oneof=debug|release"`
+//	Paths []string `olive:"paths,short=p,default=src|lib"`
+//
+// The supported options are `short`, `required`, `min` and `max` (numeric
+// fields), `oneof` (string fields which become selectors) and `default`.  The
+// default value of a list is separated by `|`.  The name defaults to the field
+// name in lowercase.  Fields of type `bool` become flags and fields of type
+// `TriState` become tri-state arguments.  The description is given by the
+// `desc` tag.  Fields which cannot be bound are skipped and their errors are
+// returned together as a `ConfigErrors`.
+func (c *Command) BindStruct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot bind value of type `%T`: expected a pointer to a struct", v)
+	}
+
+	var errs ConfigErrors
+
+	rv = rv.Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+
+		tag, ok := field.Tag.Lookup("olive")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		if err := c.bindField(field, rv.Field(i), tag); err != nil {
+			errs = append(errs, fmt.Errorf("field `%s`: %w", field.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// triStateType is the type of fields bound to tri-state arguments
+var triStateType = reflect.TypeOf(TriStateAuto)
+
+// bindTag is the parsed `olive` tag of a struct field
+type bindTag struct {
+	def          ArgDef
+	min, max     string
+	defaultValue string
+	hasDefault   bool
+}
+
+// parseBindTag parses the `olive` tag of a struct field
+func parseBindTag(field reflect.StructField, tag string) (*bindTag, error) {
+	parts := strings.Split(tag, ",")
+
+	bt := &bindTag{}
+	bt.def.Name = parts[0]
+	if bt.def.Name == "" {
+		bt.def.Name = strings.ToLower(field.Name)
+	}
+
+	bt.def.Description = field.Tag.Get("desc")
+
+	for _, opt := range parts[1:] {
+		key, val := opt, ""
+		if n := strings.IndexRune(opt, '='); n > -1 {
+			key, val = opt[:n], opt[n+1:]
+		}
+
+		switch key {
+		case "short":
+			bt.def.ShortName = val
+		case "required":
+			bt.def.Required = true
+		case "min":
+			bt.min = val
+		case "max":
+			bt.max = val
+		case "oneof":
+			bt.def.PossibleValues = strings.Split(val, "|")
+		case "default":
+			bt.defaultValue, bt.hasDefault = val, true
+		default:
+			return nil, fmt.Errorf("unknown option `%s`", key)
+		}
+	}
+
+	return bt, nil
+}
+
+// bindField defines the flag or argument for a single struct field
+func (c *Command) bindField(field reflect.StructField, fv reflect.Value, tag string) error {
+	if !unicode.IsUpper([]rune(field.Name)[0]) {
+		return errors.New("cannot bind an unexported field")
+	}
+
+	bt, err := parseBindTag(field, tag)
+	if err != nil {
+		return err
+	}
+
+	isNumeric := (field.Type.Kind() == reflect.Int && field.Type != triStateType) || field.Type.Kind() == reflect.Float64
+	if (bt.min != "" || bt.max != "") && !isNumeric {
+		return errors.New("`min` and `max` can only be used on numeric fields")
+	}
+
+	if bt.def.PossibleValues != nil && field.Type.Kind() != reflect.String {
+		return errors.New("`oneof` can only be used on string fields")
+	}
+
+	if field.Type == triStateType {
+		return c.bindTriStateField(fv, bt)
+	}
+
+	switch field.Type.Kind() {
+	case reflect.Bool:
+		return c.bindFlagField(fv, bt)
+	case reflect.Int:
+		return c.bindIntField(fv, bt)
+	case reflect.Float64:
+		return c.bindFloatField(fv, bt)
+	case reflect.String:
+		return c.bindStringField(fv, bt)
+	case reflect.Slice:
+		if field.Type.Elem().Kind() == reflect.String {
+			return c.bindStringListField(fv, bt)
+		}
+	}
+
+	return fmt.Errorf("cannot bind a field of type `%s`", field.Type)
+}
+
+func (c *Command) bindFlagField(fv reflect.Value, bt *bindTag) error {
+	if bt.def.Required || bt.hasDefault {
+		return errors.New("flags cannot be required or have a default value")
+	}
+
+	if err := c.checkFlag(bt.def.Name, bt.def.ShortName); err != nil {
+		return err
+	}
+
+	name := bt.def.Name
+	c.AddFlag(name, bt.def.ShortName, bt.def.Description)
+
+	c.bindings = append(c.bindings, func(res *ArgParseResult) {
+		if res.HasFlag(name) {
+			fv.SetBool(true)
+		}
+	})
+
+	return nil
+}
+
+func (c *Command) bindIntField(fv reflect.Value, bt *bindTag) error {
+	bt.def.Type = IntArgType
+
+	var bounds [2]*int
+	for i, bound := range []string{bt.min, bt.max} {
+		if bound != "" {
+			n, err := strconv.Atoi(bound)
+			if err != nil {
+				return fmt.Errorf("invalid bound `%s`", bound)
+			}
+
+			bounds[i] = &n
+		}
+	}
+
+	validator := func(v int) error {
+		if bounds[0] != nil && v < *bounds[0] {
+			return fmt.Errorf("must be at least %d", *bounds[0])
+		}
+
+		if bounds[1] != nil && v > *bounds[1] {
+			return fmt.Errorf("must be at most %d", *bounds[1])
+		}
+
+		return nil
+	}
+
+	if bt.hasDefault {
+		v, err := strconv.Atoi(bt.defaultValue)
+		if err != nil {
+			return fmt.Errorf("invalid default value `%s`", bt.defaultValue)
+		}
+
+		if err := validator(v); err != nil {
+			return fmt.Errorf("invalid default value `%s`: %w", bt.defaultValue, err)
+		}
+
+		bt.def.Default = v
+	}
+
+	if err := c.addArgDef(bt.def); err != nil {
+		return err
+	}
+
+	c.args[bt.def.Name].(*IntArgument).SetValidator(validator)
+	c.bindArgField(fv, bt.def.Name)
+	return nil
+}
+
+func (c *Command) bindFloatField(fv reflect.Value, bt *bindTag) error {
+	bt.def.Type = FloatArgType
+
+	var bounds [2]*float64
+	for i, bound := range []string{bt.min, bt.max} {
+		if bound != "" {
+			n, err := strconv.ParseFloat(bound, 64)
+			if err != nil {
+				return fmt.Errorf("invalid bound `%s`", bound)
+			}
+
+			bounds[i] = &n
+		}
+	}
+
+	validator := func(v float64) error {
+		if bounds[0] != nil && v < *bounds[0] {
+			return fmt.Errorf("must be at least %g", *bounds[0])
+		}
+
+		if bounds[1] != nil && v > *bounds[1] {
+			return fmt.Errorf("must be at most %g", *bounds[1])
+		}
+
+		return nil
+	}
+
+	if bt.hasDefault {
+		v, err := strconv.ParseFloat(bt.defaultValue, 64)
+		if err != nil {
+			return fmt.Errorf("invalid default value `%s`", bt.defaultValue)
+		}
+
+		if err := validator(v); err != nil {
+			return fmt.Errorf("invalid default value `%s`: %w", bt.defaultValue, err)
+		}
+
+		bt.def.Default = v
+	}
+
+	if err := c.addArgDef(bt.def); err != nil {
+		return err
+	}
+
+	c.args[bt.def.Name].(*FloatArgument).SetValidator(validator)
+	c.bindArgField(fv, bt.def.Name)
+	return nil
+}
+
+func (c *Command) bindStringField(fv reflect.Value, bt *bindTag) error {
+	bt.def.Type = StringArgType
+	if bt.def.PossibleValues != nil {
+		bt.def.Type = SelectorArgType
+	}
+
+	if bt.hasDefault {
+		bt.def.Default = bt.defaultValue
+	}
+
+	if err := c.addArgDef(bt.def); err != nil {
+		return err
+	}
+
+	c.bindArgField(fv, bt.def.Name)
+	return nil
+}
+
+func (c *Command) bindStringListField(fv reflect.Value, bt *bindTag) error {
+	bt.def.Type = StringListArgType
+
+	if bt.hasDefault {
+		bt.def.Default = strings.Split(bt.defaultValue, "|")
+	}
+
+	if err := c.addArgDef(bt.def); err != nil {
+		return err
+	}
+
+	c.bindArgField(fv, bt.def.Name)
+	return nil
+}
+
+func (c *Command) bindTriStateField(fv reflect.Value, bt *bindTag) error {
+	if err := c.checkArg(bt.def.Name, bt.def.ShortName); err != nil {
+		return err
+	}
+
+	ta := &TriStateArgument{
+		argumentBase: argumentBase{
+			name:      bt.def.Name,
+			shortName: bt.def.ShortName,
+			desc:      bt.def.Description,
+			required:  bt.def.Required,
+		},
+	}
+
+	if bt.hasDefault {
+		v, err := ta.checkValue(bt.defaultValue)
+		if err != nil {
+			return fmt.Errorf("invalid default value `%s`", bt.defaultValue)
+		}
+
+		ta.defaultValue = v
+	}
+
+	c.addArg(ta)
+	c.bindArgField(fv, bt.def.Name)
+	return nil
+}
+
+// bindArgField writes the value of the named argument into a struct field after
+// parsing.  The field is left unchanged if the argument has no value.
+func (c *Command) bindArgField(fv reflect.Value, name string) {
+	c.bindings = append(c.bindings, func(res *ArgParseResult) {
+		if val, ok := res.Arguments[name]; ok {
+			fv.Set(reflect.ValueOf(val).Convert(fv.Type()))
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package olive
+
+import "encoding/json"
+
+// completionCommandJSON is the JSON representation of a command in the
+// completion spec
+type completionCommandJSON struct {
+	Name            string                   `json:"name"`
+	PrimaryArgument bool                     `json:"primaryArgument"`
+	Arguments       []completionArgumentJSON `json:"arguments"`
+	Flags           []completionFlagJSON     `json:"flags"`
+	Subcommands     []completionCommandJSON  `json:"subcommands"`
+}
+
+// completionArgumentJSON is the JSON representation of a named argument in the
+// completion spec
+type completionArgumentJSON struct {
+	Name          string   `json:"name"`
+	ShortName     string   `json:"shortName"`
+	TakesValue    bool     `json:"takesValue"`
+	OptionalValue bool     `json:"optionalValue"`
+	Candidates    []string `json:"candidates,omitempty"`
+
+	CandidateDescriptions map[string]string `json:"candidateDescriptions,omitempty"`
+}
+
+// completionFlagJSON is the JSON representation of a flag in the completion
+// spec
+type completionFlagJSON struct {
+	Name      string `json:"name"`
+	ShortName string `json:"shortName"`
+	Negatable bool   `json:"negatable,omitempty"`
+}
+
+// CompletionSpec returns a JSON description of the command and all of its
+// subcommands for use by external completion engines.  It describes which
+// arguments take values and the candidate values of arguments with a finite
+// set of values along with the descriptions of the values of selectors.
+// Disabled and hidden flags and arguments are omitted.  Since the availability
+// of subcommands set by `SetAvailableIf` depends on the arguments given, it is
+// not applied: all subcommands are described.
+func (c *Command) CompletionSpec() ([]byte, error) {
+	return json.Marshal(newCompletionCommandJSON(c))
+}
+
+// newCompletionCommandJSON converts a command into its completion spec
+func newCompletionCommandJSON(c *Command) completionCommandJSON {
+	cj := completionCommandJSON{
+		Name:            c.Name,
+		PrimaryArgument: c.primaryArg != nil,
+		Arguments:       []completionArgumentJSON{},
+		Flags:           []completionFlagJSON{},
+		Subcommands:     []completionCommandJSON{},
+	}
+
+	for _, arg := range c.Arguments() {
+		if !arg.Enabled() || arg.base().hidden {
+			continue
+		}
+
+		aj := completionArgumentJSON{
+			Name:          arg.Name(),
+			ShortName:     arg.ShortName(),
+			TakesValue:    true,
+			OptionalValue: arg.base().hasImpliedValue,
+			Candidates:    completionCandidates(arg),
+		}
+
+		if sea, ok := arg.(*SelectorArgument); ok {
+			aj.CandidateDescriptions = sea.ValueDescriptions()
+		}
+
+		cj.Arguments = append(cj.Arguments, aj)
+	}
+
+	for _, flag := range c.Flags() {
+		if flag.Enabled() && !flag.hidden {
+			cj.Flags = append(cj.Flags, completionFlagJSON{
+				Name:      flag.Name(),
+				ShortName: flag.ShortName(),
+				Negatable: flag.negatable,
+			})
+		}
+	}
+
+	for _, subc := range c.Subcommands() {
+		cj.Subcommands = append(cj.Subcommands, newCompletionCommandJSON(subc))
+	}
+
+	return cj
+}
+
+// completionCandidates returns the values an argument can take if it only takes
+// a finite set of values
+func completionCandidates(arg Argument) []string {
+	switch v := arg.(type) {
+	case *SelectorArgument:
+		return v.PossibleValues()
+	case *TriStateArgument:
+		return triStateNames
+	case *ChoiceArgument:
+		return v.Choices()
+	}
+
+	return nil
+}
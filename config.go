@@ -1,11 +1,16 @@
 package olive
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math/bits"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 // Flag represents a flag that when encountered stores true
@@ -13,6 +18,21 @@ type Flag struct {
 	name, shortName string
 	desc            string
 	action          func()
+	disabled        bool
+	priority        bool
+	allowRepeat     bool
+	countable       bool
+	negatable       bool
+	hidden          bool
+
+	// disables is the name of the argument the flag disables if it is the
+	// disable flag of an argument
+	disables string
+
+	// cmdAction is an internal action which is passed the parser when the flag
+	// is encountered so that it can act on the deepest command that has been
+	// entered (eg. for help)
+	cmdAction func(*argParser)
 }
 
 // Name gets the name of the flag
@@ -35,6 +55,78 @@ func (f *Flag) SetAction(fn func()) {
 	f.action = fn
 }
 
+// SetPriority gives the flag priority over errors in the arguments: if parsing
+// fails, the action of a priority flag given anywhere after the offending
+// argument is still run (eg. so `--bogus --help` displays help).  The builtin
+// help flag has priority.
+func (f *Flag) SetPriority() {
+	f.priority = true
+}
+
+// SetAllowRepeat allows the flag to be given multiple times.  Repeating the
+// flag has no effect: its action is only run the first time it is given.
+func (f *Flag) SetAllowRepeat() {
+	f.allowRepeat = true
+}
+
+// SetCountable makes the flag count the number of times it is given (eg.
+// `-vvv` for a verbosity level).  A count can also be given explicitly as the
+// value of the flag (eg. `--verbose=3`).  The action of the flag is only run
+// the first time it is given.
+func (f *Flag) SetCountable() {
+	f.countable = true
+}
+
+// SetNegatable allows the flag to be negated by prefixing its name with `no-`
+// (eg. `--no-verbose`) so that a flag which is on by default can be turned off
+// explicitly.  A flag cannot be given together with its negation.
+func (f *Flag) SetNegatable() {
+	f.negatable = true
+}
+
+// SetHidden hides the flag from help (eg. for experimental flags).  A hidden flag
+// is still parsed like any other flag.
+func (f *Flag) SetHidden() {
+	f.hidden = true
+}
+
+// HasAction indicates whether or not the flag runs an action when it is
+// encountered.  This includes the builtin help flag.
+func (f *Flag) HasAction() bool {
+	return f.action != nil || f.cmdAction != nil
+}
+
+// usageName returns the name of the flag displayed in help which includes the
+// negation prefix of negatable flags (eg. `[no-]verbose`)
+func (f *Flag) usageName() string {
+	if f.negatable {
+		return "[no-]" + f.name
+	}
+
+	return f.name
+}
+
+// isHelp checks whether the flag is the builtin help flag
+func (f *Flag) isHelp() bool {
+	return f.name == "help" && f.cmdAction != nil
+}
+
+// isVersion checks whether the flag is the builtin version flag
+func (f *Flag) isVersion() bool {
+	return f.name == "version" && f.cmdAction != nil
+}
+
+// SetEnabled enables or disables the flag.  A disabled flag is treated as
+// unknown during parsing and is not displayed in help.
+func (f *Flag) SetEnabled(enabled bool) {
+	f.disabled = !enabled
+}
+
+// Enabled indicates whether or not the flag is enabled
+func (f *Flag) Enabled() bool {
+	return !f.disabled
+}
+
 // -----------------------------------------------------------------------------
 
 // Argument represents a value that can be passed to the application via a
@@ -56,11 +148,25 @@ type Argument interface {
 	// GetDefaultValue gets the default value of the argument
 	GetDefaultValue() (interface{}, bool)
 
+	// SetEnabled enables or disables the argument.  A disabled argument is
+	// treated as unknown during parsing and is not displayed in help.
+	SetEnabled(bool)
+
+	// Enabled indicates whether or not the argument is enabled
+	Enabled() bool
+
 	// checkValue is the function used by the parser to check argument values as
 	// it collect them.  It returns an "any type" which contains the typed value
 	// of the argument and an error indicating whether or not the argument value
 	// was accepted
 	checkValue(string) (interface{}, error)
+
+	// validate runs the validator of the argument on a value which has already
+	// been converted by `checkValue`.  The raw value is used in errors.
+	validate(raw string, value interface{}) error
+
+	// base returns the common fields of the argument
+	base() *argumentBase
 }
 
 // argumentBase is the base type for all special argument kinds
@@ -69,6 +175,32 @@ type argumentBase struct {
 	desc            string
 	required        bool
 	defaultValue    interface{}
+	disabled        bool
+	example         string
+	inheritDefault  bool
+	lazyValidation  bool
+
+	// impliedValue is the raw value the argument receives when it is given
+	// without a value if its value is optional
+	impliedValue    string
+	hasImpliedValue bool
+
+	// hidden indicates that the argument is not displayed in help
+	hidden bool
+
+	// defaultDisplayOnly indicates that the default value is only displayed and
+	// never given to the argument
+	defaultDisplayOnly bool
+
+	// envVar is the environment variable the argument takes its value from if
+	// it is not given
+	envVar string
+
+	// command is the command the argument belongs to
+	command *Command
+
+	// disableFlag is the companion flag which disables the argument
+	disableFlag *Flag
 }
 
 func (ab *argumentBase) Name() string {
@@ -91,11 +223,120 @@ func (ab *argumentBase) GetDefaultValue() (interface{}, bool) {
 	return ab.defaultValue, ab.defaultValue != nil
 }
 
+func (ab *argumentBase) SetEnabled(enabled bool) {
+	ab.disabled = !enabled
+}
+
+func (ab *argumentBase) Enabled() bool {
+	return !ab.disabled
+}
+
+// SetEnvVar sets an environment variable (eg. `DB_URL`) whose value is used for
+// the argument if it is not given.  The value of the variable takes precedence
+// over the default value of the argument.
+func (ab *argumentBase) SetEnvVar(name string) {
+	ab.envVar = name
+}
+
+// SetExample sets an example value for the argument which is displayed in help
+func (ab *argumentBase) SetExample(example string) {
+	ab.example = example
+}
+
+// Example returns the example value of the argument
+func (ab *argumentBase) Example() string {
+	return ab.example
+}
+
+// InheritDefault makes the argument default to the value of the argument with
+// the same name on the closest parent command that has a value for it.  The
+// argument's own default value is only used if no parent has a value.
+func (ab *argumentBase) InheritDefault() {
+	ab.inheritDefault = true
+}
+
+// SetLazyValidation defers running the validator of the argument until
+// `Validate` is called on the result containing its value.  This is useful for
+// values such as paths which may only become valid after parsing.
+func (ab *argumentBase) SetLazyValidation() {
+	ab.lazyValidation = true
+}
+
+// SetOptionalValue makes the value of the argument optional.  If the argument
+// is given without a value (eg. `--log`), it receives the implied value.  An
+// argument with an optional value only takes a value through the assignment
+// separator (eg. `--log=debug`): the next argument token is never used as its
+// value so `--log debug` gives `debug` to the command instead.  The implied
+// value is checked like any other value when the argument is given.
+func (ab *argumentBase) SetOptionalValue(implied string) {
+	ab.impliedValue = implied
+	ab.hasImpliedValue = true
+}
+
+// SetHidden hides the argument from help.  A hidden argument is still parsed
+// like any other argument: in particular, it is still required if it is marked
+// as required.
+func (ab *argumentBase) SetHidden() {
+	ab.hidden = true
+}
+
+// SetDefaultDisplayOnly makes the default value of the argument purely
+// informational: it is still displayed (eg. in `HelpJSON`) but the argument
+// does not receive it when it is not given.  `WasProvided` can be used to apply
+// the default conditionally.
+func (ab *argumentBase) SetDefaultDisplayOnly() {
+	ab.defaultDisplayOnly = true
+}
+
+// SetDisableFlag adds a companion flag to the argument's command which disables
+// the argument (eg. `--no-cache` for `--cache`).  When the flag is given, the
+// argument does not receive a value, not even its default value, and it is not
+// required.  The argument and its disable flag cannot be given together.
+func (ab *argumentBase) SetDisableFlag(name, shortName string) *Flag {
+	ab.disableFlag = ab.command.AddFlag(name, shortName, fmt.Sprintf("Disable --%s", ab.name))
+	ab.disableFlag.disables = ab.name
+	return ab.disableFlag
+}
+
+func (ab *argumentBase) base() *argumentBase {
+	return ab
+}
+
+// validatorError wraps an error returned by a validator with the name of the
+// argument and the raw value that was rejected
+func (ab *argumentBase) validatorError(val string, err error) error {
+	return &ValidationError{Arg: ab.name, Value: val, Err: err}
+}
+
+// invalidValueError creates an error for a value which is not of the type of the
+// argument or not one of its possible values
+func (ab *argumentBase) invalidValueError(val string, err error) error {
+	return &InvalidValueError{Arg: ab.name, Value: val, Err: err}
+}
+
+// numberError converts an error returned by `strconv` when parsing the value of
+// a numeric argument into a more readable error
+func (ab *argumentBase) numberError(val string, err error, kind string) error {
+	if errors.Is(err, strconv.ErrRange) {
+		if strings.HasPrefix(val, "-") {
+			return &RangeError{Arg: ab.name, Value: val, Err: fmt.Errorf("argument \"%s\" value \"%s\" is too small", ab.name, val)}
+		}
+
+		return &RangeError{Arg: ab.name, Value: val, Err: fmt.Errorf("argument \"%s\" value \"%s\" is too large", ab.name, val)}
+	}
+
+	return ab.invalidValueError(val, fmt.Errorf("argument \"%s\" value \"%s\" must be %s", ab.name, val, kind))
+}
+
 // IntArgument is an argument whose value must be an integer
 type IntArgument struct {
 	argumentBase
 
 	validator func(int) error
+
+	// hasRange indicates whether the value must lie within `min` and `max`
+	hasRange bool
+	min, max int
 }
 
 // SetValidator sets a validation function for this argument
@@ -103,11 +344,35 @@ func (ia *IntArgument) SetValidator(v func(int) error) {
 	ia.validator = v
 }
 
+// SetRange sets the inclusive bounds of the value of this argument.  The range is
+// checked before the validator of the argument: both must accept the value.  A
+// default value which has already been set must also lie within the range.
+func (ia *IntArgument) SetRange(min, max int) {
+	if min > max {
+		log.Fatalf("invalid range [%d, %d] for argument `%s`\n", min, max, ia.name)
+	}
+
+	ia.hasRange = true
+	ia.min, ia.max = min, max
+
+	if v, ok := ia.defaultValue.(int); ok && !ia.lazyValidation {
+		if err := ia.checkRange(v); err != nil {
+			log.Fatalf("range error: %s\n", err.Error())
+		}
+	}
+}
+
 // SetDefaultValue sets the default value of this argument
 func (ia *IntArgument) SetDefaultValue(v int) {
-	if ia.validator != nil {
-		if err := ia.validator(v); err != nil {
-			log.Fatalf("validator error: %s\n", err.Error())
+	if !ia.lazyValidation {
+		if err := ia.checkRange(v); err != nil {
+			log.Fatalf("range error: %s\n", err.Error())
+		}
+
+		if ia.validator != nil {
+			if err := ia.validator(v); err != nil {
+				log.Fatalf("validator error: %s\n", err.Error())
+			}
 		}
 	}
 
@@ -119,12 +384,12 @@ func (ia *IntArgument) checkValue(val string) (interface{}, error) {
 	// the platform (this should realistically never be an issue)
 	raw, err := strconv.ParseInt(val, 0, bits.UintSize)
 	if err != nil {
-		return nil, err
+		return nil, ia.numberError(val, err, "an integer")
 	}
 
 	v := int(raw)
-	if ia.validator != nil {
-		if err := ia.validator(v); err != nil {
+	if !ia.lazyValidation {
+		if err := ia.validate(val, v); err != nil {
 			return nil, err
 		}
 	}
@@ -132,11 +397,42 @@ func (ia *IntArgument) checkValue(val string) (interface{}, error) {
 	return v, nil
 }
 
+func (ia *IntArgument) validate(raw string, value interface{}) error {
+	if err := ia.checkRange(value.(int)); err != nil {
+		return err
+	}
+
+	if ia.validator != nil {
+		if err := ia.validator(value.(int)); err != nil {
+			return ia.validatorError(raw, err)
+		}
+	}
+
+	return nil
+}
+
+// checkRange checks that a value lies within the range of the argument if any
+func (ia *IntArgument) checkRange(v int) error {
+	if ia.hasRange && (v < ia.min || v > ia.max) {
+		return &RangeError{
+			Arg:   ia.name,
+			Value: strconv.Itoa(v),
+			Err:   fmt.Errorf("argument \"%s\" value %d out of range [%d, %d]", ia.name, v, ia.min, ia.max),
+		}
+	}
+
+	return nil
+}
+
 // FloatArgument is an argument whose value must be a float
 type FloatArgument struct {
 	argumentBase
 
 	validator func(float64) error
+
+	// hasRange indicates whether the value must lie within `min` and `max`
+	hasRange bool
+	min, max float64
 }
 
 // SetValidator sets a validation function for this argument
@@ -144,11 +440,35 @@ func (fa *FloatArgument) SetValidator(v func(float64) error) {
 	fa.validator = v
 }
 
+// SetRange sets the inclusive bounds of the value of this argument.  The range is
+// checked before the validator of the argument: both must accept the value.  A
+// default value which has already been set must also lie within the range.
+func (fa *FloatArgument) SetRange(min, max float64) {
+	if !(min <= max) {
+		log.Fatalf("invalid range [%g, %g] for argument `%s`\n", min, max, fa.name)
+	}
+
+	fa.hasRange = true
+	fa.min, fa.max = min, max
+
+	if v, ok := fa.defaultValue.(float64); ok && !fa.lazyValidation {
+		if err := fa.checkRange(v); err != nil {
+			log.Fatalf("range error: %s\n", err.Error())
+		}
+	}
+}
+
 // SetDefaultValue sets the default value of this argument
 func (fa *FloatArgument) SetDefaultValue(v float64) {
-	if fa.validator != nil {
-		if err := fa.validator(v); err != nil {
-			log.Fatalf("validator error: %s\n", err.Error())
+	if !fa.lazyValidation {
+		if err := fa.checkRange(v); err != nil {
+			log.Fatalf("range error: %s\n", err.Error())
+		}
+
+		if fa.validator != nil {
+			if err := fa.validator(v); err != nil {
+				log.Fatalf("validator error: %s\n", err.Error())
+			}
 		}
 	}
 
@@ -159,11 +479,86 @@ func (fa *FloatArgument) checkValue(val string) (interface{}, error) {
 	v, err := strconv.ParseFloat(val, 64)
 
 	if err != nil {
-		return nil, err
+		return nil, fa.numberError(val, err, "a number")
+	}
+
+	if !fa.lazyValidation {
+		if err := fa.validate(val, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+func (fa *FloatArgument) validate(raw string, value interface{}) error {
+	if err := fa.checkRange(value.(float64)); err != nil {
+		return err
 	}
 
 	if fa.validator != nil {
-		if err := fa.validator(v); err != nil {
+		if err := fa.validator(value.(float64)); err != nil {
+			return fa.validatorError(raw, err)
+		}
+	}
+
+	return nil
+}
+
+// checkRange checks that a value lies within the range of the argument if any.
+// NaN never lies within a range.
+func (fa *FloatArgument) checkRange(v float64) error {
+	if fa.hasRange && !(v >= fa.min && v <= fa.max) {
+		return &RangeError{
+			Arg:   fa.name,
+			Value: strconv.FormatFloat(v, 'g', -1, 64),
+			Err:   fmt.Errorf("argument \"%s\" value %g out of range [%g, %g]", fa.name, v, fa.min, fa.max),
+		}
+	}
+
+	return nil
+}
+
+// BoolArgument is an argument whose value must be a boolean (eg.
+// `--enabled=false`).  Unlike a flag, its value is given explicitly.
+type BoolArgument struct {
+	argumentBase
+
+	validator func(bool) error
+}
+
+// SetValidator sets a validation function for this argument
+func (ba *BoolArgument) SetValidator(v func(bool) error) {
+	ba.validator = v
+}
+
+// SetDefaultValue sets the default value of this argument
+func (ba *BoolArgument) SetDefaultValue(v bool) {
+	if ba.validator != nil && !ba.lazyValidation {
+		if err := ba.validator(v); err != nil {
+			log.Fatalf("validator error: %s\n", err.Error())
+		}
+	}
+
+	ba.defaultValue = v
+}
+
+func (ba *BoolArgument) checkValue(val string) (interface{}, error) {
+	var v bool
+	switch strings.ToLower(val) {
+	case "yes":
+		v = true
+	case "no":
+		v = false
+	default:
+		var err error
+		if v, err = strconv.ParseBool(val); err != nil {
+			return nil, ba.invalidValueError(val, fmt.Errorf("argument \"%s\" value \"%s\" must be a boolean", ba.name, val))
+		}
+	}
+
+	if !ba.lazyValidation {
+		if err := ba.validate(val, v); err != nil {
 			return nil, err
 		}
 	}
@@ -171,11 +566,25 @@ func (fa *FloatArgument) checkValue(val string) (interface{}, error) {
 	return v, nil
 }
 
+func (ba *BoolArgument) validate(raw string, value interface{}) error {
+	if ba.validator != nil {
+		if err := ba.validator(value.(bool)); err != nil {
+			return ba.validatorError(raw, err)
+		}
+	}
+
+	return nil
+}
+
 // StringArgument is an argument whose value must be a string
 type StringArgument struct {
 	argumentBase
 
 	validator func(string) error
+	codec     func(string) (interface{}, error)
+
+	// rawDefault is the default value before it was decoded by the codec
+	rawDefault string
 }
 
 // SetValidator sets a validation function for this argument
@@ -183,27 +592,192 @@ func (sa *StringArgument) SetValidator(v func(string) error) {
 	sa.validator = v
 }
 
+// SetCodec sets a function which decodes the values of this argument after
+// they are validated (eg. to decode a base64 or JSON payload).  The decoded
+// value is stored in the result in place of the string given.  The default
+// value of the argument is decoded as well.
+func (sa *StringArgument) SetCodec(decode func(string) (interface{}, error)) {
+	sa.codec = decode
+
+	if raw, ok := sa.defaultValue.(string); ok {
+		sa.SetDefaultValue(raw)
+	}
+}
+
 // SetDefaultValue sets the default value of this argument
 func (sa *StringArgument) SetDefaultValue(v string) {
-	if sa.validator != nil {
+	if sa.validator != nil && !sa.lazyValidation {
 		if err := sa.validator(v); err != nil {
 			log.Fatalf("validator error: %s\n", err.Error())
 		}
 	}
 
+	if sa.codec != nil {
+		val, err := sa.decode(v)
+		if err != nil {
+			log.Fatalf("default value error: %s\n", err.Error())
+		}
+
+		sa.defaultValue = val
+		sa.rawDefault = v
+		return
+	}
+
 	sa.defaultValue = v
 }
 
 func (sa *StringArgument) checkValue(val string) (interface{}, error) {
-	if sa.validator != nil {
-		if err := sa.validator(val); err != nil {
+	if !sa.lazyValidation {
+		if err := sa.validate(val, val); err != nil {
 			return nil, err
 		}
 	}
 
+	if sa.codec != nil {
+		return sa.decode(val)
+	}
+
 	return val, nil
 }
 
+// validate runs the validator on the raw value since the stored value may have
+// been decoded
+func (sa *StringArgument) validate(raw string, value interface{}) error {
+	if sa.validator != nil {
+		if err := sa.validator(raw); err != nil {
+			return sa.validatorError(raw, err)
+		}
+	}
+
+	return nil
+}
+
+// decode runs the codec of the argument on a value
+func (sa *StringArgument) decode(val string) (interface{}, error) {
+	decoded, err := sa.codec(val)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode value `%s` of argument [%s]: %w", val, sa.name, err)
+	}
+
+	return decoded, nil
+}
+
+// PathArgument is an argument whose value is a file system path.  The value
+// stored is the cleaned absolute path.
+type PathArgument struct {
+	argumentBase
+
+	validator func(string) error
+
+	// mustExist, mustBeDir and mustBeFile are the constraints on the file
+	// system entry at the path checked when the argument is parsed
+	mustExist, mustBeDir, mustBeFile bool
+}
+
+// SetValidator sets a validation function for this argument.  It is given the
+// absolute path.
+func (pa *PathArgument) SetValidator(v func(string) error) {
+	pa.validator = v
+}
+
+// MustExist requires the path given to exist
+func (pa *PathArgument) MustExist() {
+	pa.mustExist = true
+}
+
+// MustBeDir requires the path given to be an existing directory
+func (pa *PathArgument) MustBeDir() {
+	pa.mustBeDir, pa.mustBeFile = true, false
+}
+
+// MustBeFile requires the path given to be an existing file which is not a
+// directory
+func (pa *PathArgument) MustBeFile() {
+	pa.mustBeFile, pa.mustBeDir = true, false
+}
+
+// SetDefaultValue sets the default value of this argument.  The default is
+// converted to an absolute path but the constraints on the file system entry
+// are not checked until the argument is parsed since the file system may change.
+func (pa *PathArgument) SetDefaultValue(v string) {
+	path, err := filepath.Abs(v)
+	if err != nil {
+		log.Fatalf("default value error: %s\n", err.Error())
+	}
+
+	if pa.validator != nil && !pa.lazyValidation {
+		if err := pa.validator(path); err != nil {
+			log.Fatalf("validator error: %s\n", err.Error())
+		}
+	}
+
+	pa.defaultValue = path
+}
+
+// checkDefault checks a default value of the argument like `SetDefaultValue`:
+// the path is converted to an absolute path and validated but the file system
+// entry at the path is not checked
+func (pa *PathArgument) checkDefault(val string) (interface{}, error) {
+	path, err := filepath.Abs(val)
+	if err != nil {
+		return nil, &PathError{Arg: pa.name, Value: val, Err: fmt.Errorf("argument \"%s\" value \"%s\" is not a valid path: %w", pa.name, val, err)}
+	}
+
+	if pa.validator != nil && !pa.lazyValidation {
+		if err := pa.validator(path); err != nil {
+			return nil, pa.validatorError(val, err)
+		}
+	}
+
+	return path, nil
+}
+
+func (pa *PathArgument) checkValue(val string) (interface{}, error) {
+	path, err := filepath.Abs(val)
+	if err != nil {
+		return nil, &PathError{Arg: pa.name, Value: val, Err: fmt.Errorf("argument \"%s\" value \"%s\" is not a valid path: %w", pa.name, val, err)}
+	}
+
+	if !pa.lazyValidation {
+		if err := pa.validate(val, path); err != nil {
+			return nil, err
+		}
+	}
+
+	return path, nil
+}
+
+// pathError creates an error for a path which does not satisfy the constraints
+// of the argument
+func (pa *PathArgument) pathError(raw string, err error) error {
+	return &PathError{Arg: pa.name, Value: raw, Err: err}
+}
+
+func (pa *PathArgument) validate(raw string, value interface{}) error {
+	if pa.mustExist || pa.mustBeDir || pa.mustBeFile {
+		info, err := os.Stat(value.(string))
+		if errors.Is(err, os.ErrNotExist) {
+			return pa.pathError(raw, fmt.Errorf("argument \"%s\": path '%s' does not exist", pa.name, raw))
+		} else if err != nil {
+			return pa.pathError(raw, fmt.Errorf("argument \"%s\": %w", pa.name, err))
+		}
+
+		if pa.mustBeDir && !info.IsDir() {
+			return pa.pathError(raw, fmt.Errorf("argument \"%s\": path '%s' is not a directory", pa.name, raw))
+		} else if pa.mustBeFile && info.IsDir() {
+			return pa.pathError(raw, fmt.Errorf("argument \"%s\": path '%s' is not a file", pa.name, raw))
+		}
+	}
+
+	if pa.validator != nil {
+		if err := pa.validator(value.(string)); err != nil {
+			return pa.validatorError(raw, err)
+		}
+	}
+
+	return nil
+}
+
 // SelectorArgument is an argument whose value is constained to a finite set of
 // string values
 type SelectorArgument struct {
@@ -211,6 +785,53 @@ type SelectorArgument struct {
 
 	possibleValues map[string]struct{}
 	validator      func(string) error
+
+	// values is the possible values in the order they were specified
+	values []string
+
+	// valueDescs is the descriptions of the possible values
+	valueDescs map[string]string
+
+	// foldedValues maps the lowercased possible values to the possible values
+	// if values are matched case-insensitively
+	foldedValues map[string]string
+}
+
+// SetCaseInsensitive makes the values of the argument match the possible values
+// regardless of case (eg. `INFO` for `info`).  The possible value matched is
+// stored as the value.  If several possible values differ only by case, the one
+// specified first is matched.
+func (sea *SelectorArgument) SetCaseInsensitive() {
+	sea.foldedValues = make(map[string]string)
+	for _, value := range sea.values {
+		folded := strings.ToLower(value)
+		if _, ok := sea.foldedValues[folded]; !ok {
+			sea.foldedValues[folded] = value
+		}
+	}
+}
+
+// SetValueDescriptions sets descriptions for the possible values of the
+// argument which are displayed in help.  Not every value needs a description.
+func (sea *SelectorArgument) SetValueDescriptions(descs map[string]string) {
+	for value := range descs {
+		if _, ok := sea.possibleValues[value]; !ok {
+			log.Fatalf("`%s` is not a possible value of argument [%s]\n", value, sea.name)
+		}
+	}
+
+	sea.valueDescs = descs
+}
+
+// ValueDescriptions returns the descriptions of the possible values
+func (sea *SelectorArgument) ValueDescriptions() map[string]string {
+	return sea.valueDescs
+}
+
+// PossibleValues returns the possible values of the argument in the order they
+// were specified
+func (sea *SelectorArgument) PossibleValues() []string {
+	return sea.values
 }
 
 // SetValidator sets a validation function for this argument
@@ -220,21 +841,83 @@ func (sea *SelectorArgument) SetValidator(v func(string) error) {
 
 // SetDefaultValue sets the default value of this argument
 func (sea *SelectorArgument) SetDefaultValue(v string) {
-	_, err := sea.checkValue(v)
+	val, err := sea.checkValue(v)
 	if err != nil {
 		log.Fatalf("default value error: %s\n", err.Error())
 	}
 
-	sea.defaultValue = v
+	sea.defaultValue = val
 }
 
 func (sea *SelectorArgument) checkValue(val string) (interface{}, error) {
-	if _, ok := sea.possibleValues[val]; !ok {
-		return nil, fmt.Errorf("`%s` is not a valid value for argument [%s]", val, sea.name)
+	value, ok := val, false
+	if sea.foldedValues != nil {
+		value, ok = sea.foldedValues[strings.ToLower(val)]
+	} else {
+		_, ok = sea.possibleValues[val]
+	}
+
+	if !ok {
+		return nil, sea.invalidValueError(val, fmt.Errorf("`%s` is not a valid value for argument [%s]", val, sea.name))
 	}
 
+	if !sea.lazyValidation {
+		if err := sea.validate(val, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return value, nil
+}
+
+func (sea *SelectorArgument) validate(raw string, value interface{}) error {
 	if sea.validator != nil {
-		if err := sea.validator(val); err != nil {
+		if err := sea.validator(value.(string)); err != nil {
+			return sea.validatorError(raw, err)
+		}
+	}
+
+	return nil
+}
+
+// PatternSelectorArgument is an argument whose value must match a pattern.  It
+// is useful in place of a selector when the possible values are too numerous
+// to list.
+type PatternSelectorArgument struct {
+	argumentBase
+
+	pattern   string
+	re        *regexp.Regexp
+	validator func(string) error
+}
+
+// Pattern returns the pattern values of the argument must match
+func (psa *PatternSelectorArgument) Pattern() string {
+	return psa.pattern
+}
+
+// SetValidator sets a validation function for this argument
+func (psa *PatternSelectorArgument) SetValidator(v func(string) error) {
+	psa.validator = v
+}
+
+// SetDefaultValue sets the default value of this argument
+func (psa *PatternSelectorArgument) SetDefaultValue(v string) {
+	_, err := psa.checkValue(v)
+	if err != nil {
+		log.Fatalf("default value error: %s\n", err.Error())
+	}
+
+	psa.defaultValue = v
+}
+
+func (psa *PatternSelectorArgument) checkValue(val string) (interface{}, error) {
+	if !psa.re.MatchString(val) {
+		return nil, psa.invalidValueError(val, fmt.Errorf("`%s` does not match the pattern `%s` of argument [%s]", val, psa.pattern, psa.name))
+	}
+
+	if !psa.lazyValidation {
+		if err := psa.validate(val, val); err != nil {
 			return nil, err
 		}
 	}
@@ -242,6 +925,352 @@ func (sea *SelectorArgument) checkValue(val string) (interface{}, error) {
 	return val, nil
 }
 
+func (psa *PatternSelectorArgument) validate(raw string, value interface{}) error {
+	if psa.validator != nil {
+		if err := psa.validator(value.(string)); err != nil {
+			return psa.validatorError(raw, err)
+		}
+	}
+
+	return nil
+}
+
+// ChoiceArgument is an argument whose value is one of a finite set of names
+// each of which maps to a typed value.  The typed value is stored in the result
+// in place of the name given.
+type ChoiceArgument struct {
+	argumentBase
+
+	choices   map[string]interface{}
+	validator func(interface{}) error
+
+	// keys is the names of the choices in sorted order
+	keys []string
+}
+
+// Choices returns the names of the choices of the argument in sorted order
+func (ca *ChoiceArgument) Choices() []string {
+	return ca.keys
+}
+
+// SetValidator sets a validation function for this argument.  It is called
+// with the typed value of the choice.
+func (ca *ChoiceArgument) SetValidator(v func(interface{}) error) {
+	ca.validator = v
+}
+
+// SetDefaultValue sets the default value of this argument to the value of the
+// choice with the given name
+func (ca *ChoiceArgument) SetDefaultValue(v string) {
+	val, err := ca.checkValue(v)
+	if err != nil {
+		log.Fatalf("default value error: %s\n", err.Error())
+	}
+
+	ca.defaultValue = val
+}
+
+func (ca *ChoiceArgument) checkValue(val string) (interface{}, error) {
+	value, ok := ca.choices[val]
+	if !ok {
+		return nil, ca.invalidValueError(val, fmt.Errorf("`%s` is not a valid value for argument [%s] (expected one of %s)", val, ca.name, strings.Join(ca.keys, ", ")))
+	}
+
+	if !ca.lazyValidation {
+		if err := ca.validate(val, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return value, nil
+}
+
+func (ca *ChoiceArgument) validate(raw string, value interface{}) error {
+	if ca.validator != nil {
+		if err := ca.validator(value); err != nil {
+			return ca.validatorError(raw, err)
+		}
+	}
+
+	return nil
+}
+
+// keyOf returns the name of the choice with the given value
+func (ca *ChoiceArgument) keyOf(value interface{}) (string, bool) {
+	for _, key := range ca.keys {
+		if reflect.DeepEqual(ca.choices[key], value) {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+// TriState is the value of a tri-state argument
+type TriState int
+
+// Enumeration of tri-state values
+const (
+	TriStateAuto TriState = iota
+	TriStateOn
+	TriStateOff
+)
+
+// triStateNames is the names of the tri-state values in the order they are
+// displayed
+var triStateNames = []string{"on", "off", "auto"}
+
+// IsOn indicates whether the value is `on`
+func (ts TriState) IsOn() bool {
+	return ts == TriStateOn
+}
+
+// IsOff indicates whether the value is `off`
+func (ts TriState) IsOff() bool {
+	return ts == TriStateOff
+}
+
+// IsAuto indicates whether the value is `auto`
+func (ts TriState) IsAuto() bool {
+	return ts == TriStateAuto
+}
+
+func (ts TriState) String() string {
+	switch ts {
+	case TriStateOn:
+		return "on"
+	case TriStateOff:
+		return "off"
+	default:
+		return "auto"
+	}
+}
+
+// MarshalText encodes the tri-state value as its name
+func (ts TriState) MarshalText() ([]byte, error) {
+	return []byte(ts.String()), nil
+}
+
+// TriStateArgument is an argument whose value is `on`, `off` or `auto`.  Its
+// value is stored as a `TriState`.
+type TriStateArgument struct {
+	argumentBase
+
+	validator func(TriState) error
+}
+
+// SetValidator sets a validation function for this argument
+func (ta *TriStateArgument) SetValidator(v func(TriState) error) {
+	ta.validator = v
+}
+
+// SetDefaultValue sets the default value of this argument
+func (ta *TriStateArgument) SetDefaultValue(v TriState) {
+	if ta.validator != nil && !ta.lazyValidation {
+		if err := ta.validator(v); err != nil {
+			log.Fatalf("validator error: %s\n", err.Error())
+		}
+	}
+
+	ta.defaultValue = v
+}
+
+func (ta *TriStateArgument) checkValue(val string) (interface{}, error) {
+	var v TriState
+	switch val {
+	case "on":
+		v = TriStateOn
+	case "off":
+		v = TriStateOff
+	case "auto":
+		v = TriStateAuto
+	default:
+		return nil, ta.invalidValueError(val, fmt.Errorf("`%s` is not a valid value for argument [%s]", val, ta.name))
+	}
+
+	if !ta.lazyValidation {
+		if err := ta.validate(val, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+func (ta *TriStateArgument) validate(raw string, value interface{}) error {
+	if ta.validator != nil {
+		if err := ta.validator(value.(TriState)); err != nil {
+			return ta.validatorError(raw, err)
+		}
+	}
+
+	return nil
+}
+
+// StringListArgument is an argument whose value is a comma-separated list of
+// strings (eg. `--paths=a,b,c`).  A separator can be included in an element by
+// escaping it with a backslash (`a\,b` is the single element `a,b`) and a
+// literal backslash is written as `\\`.  A single trailing separator is ignored.
+// The argument can be given multiple times in which case the elements of all
+// its values are collected in order (eg. `-I=/a -I=/b`).
+type StringListArgument struct {
+	argumentBase
+
+	validator func(string) error
+}
+
+// SetValidator sets a validation function which is applied to every element of
+// the list
+func (sla *StringListArgument) SetValidator(v func(string) error) {
+	sla.validator = v
+}
+
+// SetDefaultValue sets the default value of this argument
+func (sla *StringListArgument) SetDefaultValue(v []string) {
+	if sla.validator != nil && !sla.lazyValidation {
+		for _, elem := range v {
+			if err := sla.validator(elem); err != nil {
+				log.Fatalf("validator error: %s\n", err.Error())
+			}
+		}
+	}
+
+	sla.defaultValue = v
+}
+
+func (sla *StringListArgument) checkValue(val string) (interface{}, error) {
+	elems := splitList(val, ',')
+
+	if !sla.lazyValidation {
+		if err := sla.validate(val, elems); err != nil {
+			return nil, err
+		}
+	}
+
+	return elems, nil
+}
+
+func (sla *StringListArgument) appendValue(prev, val interface{}) interface{} {
+	elems := append([]string(nil), prev.([]string)...)
+	return append(elems, val.([]string)...)
+}
+
+func (sla *StringListArgument) validate(raw string, value interface{}) error {
+	if sla.validator != nil {
+		for _, elem := range value.([]string) {
+			if err := sla.validator(elem); err != nil {
+				return sla.validatorError(elem, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// IntListArgument is an argument whose value is an integer which can be given
+// multiple times to collect a list of integers (eg. `--port=80 --port=443`)
+type IntListArgument struct {
+	argumentBase
+
+	validator func(int) error
+}
+
+// SetValidator sets a validation function which is applied to every element of
+// the list
+func (ila *IntListArgument) SetValidator(v func(int) error) {
+	ila.validator = v
+}
+
+// SetDefaultValue sets the default value of this argument
+func (ila *IntListArgument) SetDefaultValue(v []int) {
+	if ila.validator != nil && !ila.lazyValidation {
+		for _, elem := range v {
+			if err := ila.validator(elem); err != nil {
+				log.Fatalf("validator error: %s\n", err.Error())
+			}
+		}
+	}
+
+	ila.defaultValue = v
+}
+
+func (ila *IntListArgument) checkValue(val string) (interface{}, error) {
+	raw, err := strconv.ParseInt(val, 0, bits.UintSize)
+	if err != nil {
+		return nil, ila.numberError(val, err, "an integer")
+	}
+
+	elems := []int{int(raw)}
+	if !ila.lazyValidation {
+		if err := ila.validate(val, elems); err != nil {
+			return nil, err
+		}
+	}
+
+	return elems, nil
+}
+
+func (ila *IntListArgument) appendValue(prev, val interface{}) interface{} {
+	elems := append([]int(nil), prev.([]int)...)
+	return append(elems, val.([]int)...)
+}
+
+func (ila *IntListArgument) validate(raw string, value interface{}) error {
+	if ila.validator != nil {
+		for _, elem := range value.([]int) {
+			if err := ila.validator(elem); err != nil {
+				return ila.validatorError(strconv.Itoa(elem), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// listArgument is an argument which can be given multiple times to collect
+// the elements of all its values
+type listArgument interface {
+	Argument
+
+	// appendValue appends the elements of a value to the previous value of the
+	// argument returning the combined value
+	appendValue(prev, val interface{}) interface{}
+}
+
+// splitList splits a list value on an unescaped separator.  A backslash escapes
+// the separator and itself; before any other character it is kept as is.
+func splitList(val string, sep rune) []string {
+	var elems []string
+	var b strings.Builder
+
+	// endsWithSep tracks whether the last rune was an unescaped separator
+	endsWithSep := false
+
+	runes := []rune(val)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		endsWithSep = false
+
+		if r == '\\' && i+1 < len(runes) && (runes[i+1] == sep || runes[i+1] == '\\') {
+			i++
+			b.WriteRune(runes[i])
+		} else if r == sep {
+			elems = append(elems, b.String())
+			b.Reset()
+			endsWithSep = true
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	// a trailing separator does not produce an empty final element
+	if !endsWithSep {
+		elems = append(elems, b.String())
+	}
+
+	return elems
+}
+
 // -----------------------------------------------------------------------------
 
 // PrimaryArgument is an argument that is passed to command without an explicit
@@ -250,6 +1279,10 @@ func (sea *SelectorArgument) checkValue(val string) (interface{}, error) {
 type PrimaryArgument struct {
 	name, desc string
 	required   bool
+
+	// variadic indicates that the primary argument collects any number of
+	// values (eg. for `rm <files...>`)
+	variadic bool
 }
 
 // Name returns the name of the primary argument
@@ -267,6 +1300,11 @@ func (pa *PrimaryArgument) Required() bool {
 	return pa.required
 }
 
+// Variadic indicates whether or not this argument collects multiple values
+func (pa *PrimaryArgument) Variadic() bool {
+	return pa.variadic
+}
+
 // -----------------------------------------------------------------------------
 
 func newCommand(name, desc string, helpEnabled bool) *Command {
@@ -279,15 +1317,96 @@ func newCommand(name, desc string, helpEnabled bool) *Command {
 		flagsByShortName:   make(map[string]*Flag),
 		argsByShortName:    make(map[string]Argument),
 		RequiresSubcommand: true,
+		WarningWriter:      os.Stderr,
+		ErrorWriter:        os.Stderr,
+		ParseErrorExitCode: 2,
+		RunErrorExitCode:   1,
+		assignSep:          "=",
 	}
 
 	if helpEnabled {
-		f := c.AddFlag("help", "h", "Get help")
-		f.action = func() {
-			c.Help()
-			os.Exit(0)
-		}
+		c.addHelpFlag()
 	}
 
 	return c
 }
+
+// root returns the initial command of the CLI this command belongs to
+func (c *Command) root() *Command {
+	for c.parent != nil {
+		c = c.parent
+	}
+
+	return c
+}
+
+// usageName returns the name of the command to display in help and usage.
+// `res` is the result of the command if help is displayed during parsing and
+// may be `nil`.
+func (c *Command) usageName(res *ArgParseResult) string {
+	if c.displayName != "" {
+		return c.displayName
+	}
+
+	if c.UseInvokedName && res != nil && res.invokedName != "" {
+		return res.invokedName
+	}
+
+	return c.Name
+}
+
+// available checks whether the command is available as a subcommand given the
+// result of its parent command
+func (c *Command) available(parentRes *ArgParseResult) bool {
+	if c.availableIf == nil {
+		return true
+	}
+
+	if parentRes == nil {
+		parentRes = &ArgParseResult{
+			flags:     make(map[string]struct{}),
+			Arguments: make(map[string]interface{}),
+		}
+	}
+
+	return c.availableIf(parentRes)
+}
+
+// exit exits the application with the given exit code using the exit function
+// of the CLI
+func (c *Command) exit(code int) {
+	if exitFunc := c.root().ExitFunc; exitFunc != nil {
+		exitFunc(code)
+	} else {
+		os.Exit(code)
+	}
+}
+
+// addVersionFlag adds the builtin version flag to the command
+func (c *Command) addVersionFlag() {
+	f := c.AddFlag("version", "V", "Display the version")
+	f.priority = true
+	f.cmdAction = func(ap *argParser) {
+		fmt.Println(c.version)
+		ap.exit(0)
+	}
+}
+
+// warnf emits a warning to the warning writer of the CLI
+func (c *Command) warnf(format string, v ...interface{}) {
+	if w := c.root().WarningWriter; w != nil {
+		fmt.Fprintf(w, "warning: "+format+"\n", v...)
+	}
+}
+
+// addHelpFlag adds the help flag to a command.  The help message displayed is
+// always that of the deepest command entered when the flag is encountered so
+// that inherited help flags do not display a parent command's help.
+func (c *Command) addHelpFlag() {
+	f := c.AddFlag("help", "h", "Get help")
+	f.priority = true
+	f.cmdAction = func(ap *argParser) {
+		fmt.Println(getHelpMessage(ap.currCommand(), ap.currResult()))
+		ap.exit(0)
+	}
+}
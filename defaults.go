@@ -0,0 +1,193 @@
+package olive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LoadDefaults reads a JSON object from a file mapping the names of arguments
+// of the CLI to their default values (eg. `{"jobs": 4, "build": {"output":
+// "out"}}`).  The defaults of the arguments of a subcommand are given as an
+// object under the name of the subcommand.  Each value must have the JSON type
+// corresponding to the kind of its argument and is checked like a default set
+// with `SetDefaultValue` (eg. the constraints of path arguments on the file
+// system are not checked).  No defaults are changed if any of them are invalid.
+func LoadDefaults(cli *Command, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var values map[string]interface{}
+	if err := dec.Decode(&values); err != nil {
+		return fmt.Errorf("invalid defaults file `%s`: %w", path, err)
+	}
+
+	defaults := make(map[Argument]interface{})
+	raws := make(map[Argument]string)
+	if err := collectDefaults(cli, values, "", defaults, raws); err != nil {
+		return fmt.Errorf("invalid defaults file `%s`: %w", path, err)
+	}
+
+	for arg, val := range defaults {
+		arg.base().defaultValue = val
+
+		// the value given is kept for arguments whose default is decoded so
+		// that it can be rendered by `ToArgs`
+		if sa, ok := arg.(*StringArgument); ok && sa.codec != nil {
+			sa.rawDefault = raws[arg]
+		}
+	}
+
+	return nil
+}
+
+// collectDefaults checks the default values given for the arguments of a
+// command and its subcommands and converts them to the values of the arguments.
+// The values given as strings are also collected in `raws`.  `prefix` is the
+// qualified name of the command used in errors.
+func collectDefaults(c *Command, values map[string]interface{}, prefix string, defaults map[Argument]interface{}, raws map[Argument]string) error {
+	for name, value := range values {
+		if subc, ok := c.subcommands[name]; ok {
+			subValues, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("defaults of subcommand `%s%s` must be an object", prefix, name)
+			}
+
+			if err := collectDefaults(subc, subValues, prefix+name+".", defaults, raws); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		arg, ok := c.args[name]
+		if !ok {
+			return fmt.Errorf("unknown argument: `%s%s`", prefix, name)
+		}
+
+		val, err := convertDefault(arg, value)
+		if err != nil {
+			return fmt.Errorf("invalid default for argument `%s%s`: %w", prefix, name, err)
+		}
+
+		defaults[arg] = val
+
+		if s, ok := value.(string); ok {
+			raws[arg] = s
+		}
+	}
+
+	return nil
+}
+
+// convertDefault converts a JSON value into the value of an argument checking
+// that its type matches the kind of the argument
+func convertDefault(arg Argument, value interface{}) (interface{}, error) {
+	switch arg.(type) {
+	case *IntArgument, *FloatArgument:
+		num, ok := value.(json.Number)
+		if !ok {
+			return nil, jsonTypeError("a number", value)
+		}
+
+		return arg.checkValue(num.String())
+	case *BoolArgument:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, jsonTypeError("a boolean", value)
+		}
+
+		return arg.checkValue(fmt.Sprint(b))
+	case *StringListArgument:
+		elems, err := jsonList(value, "a string", func(elem interface{}) bool {
+			_, ok := elem.(string)
+			return ok
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		strs := make([]string, len(elems))
+		for i, elem := range elems {
+			strs[i] = elem.(string)
+		}
+
+		return arg.checkValue(formatValue(strs))
+	case *IntListArgument:
+		elems, err := jsonList(value, "a number", func(elem interface{}) bool {
+			_, ok := elem.(json.Number)
+			return ok
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ila := arg.(*IntListArgument)
+		val := interface{}([]int{})
+		for _, elem := range elems {
+			elemVal, err := arg.checkValue(elem.(json.Number).String())
+			if err != nil {
+				return nil, err
+			}
+
+			val = ila.appendValue(val, elemVal)
+		}
+
+		return val, nil
+	}
+
+	// all the other kinds of arguments take their values from strings
+	s, ok := value.(string)
+	if !ok {
+		return nil, jsonTypeError("a string", value)
+	}
+
+	if pa, ok := arg.(*PathArgument); ok {
+		return pa.checkDefault(s)
+	}
+
+	return arg.checkValue(s)
+}
+
+// jsonList checks that a JSON value is a list whose elements all satisfy `ok`
+func jsonList(value interface{}, elemKind string, ok func(interface{}) bool) ([]interface{}, error) {
+	elems, isList := value.([]interface{})
+	if !isList {
+		return nil, jsonTypeError("a list", value)
+	}
+
+	for _, elem := range elems {
+		if !ok(elem) {
+			return nil, jsonTypeError(elemKind+" for every element", elem)
+		}
+	}
+
+	return elems, nil
+}
+
+// jsonTypeError creates an error for a JSON value of the wrong type
+func jsonTypeError(expected string, value interface{}) error {
+	var actual string
+	switch value.(type) {
+	case nil:
+		actual = "null"
+	case bool:
+		actual = "a boolean"
+	case json.Number:
+		actual = "a number"
+	case string:
+		actual = "a string"
+	case []interface{}:
+		actual = "a list"
+	case map[string]interface{}:
+		actual = "an object"
+	}
+
+	return fmt.Errorf("expected %s, got %s", expected, actual)
+}
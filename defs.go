@@ -0,0 +1,163 @@
+package olive
+
+import "fmt"
+
+// FlagDef is a specification of a flag used to register many flags at once
+type FlagDef struct {
+	Name, ShortName string
+	Description     string
+}
+
+// ArgType enumerates the different kinds of named arguments
+type ArgType int
+
+// Enumeration of argument types
+const (
+	IntArgType ArgType = iota
+	FloatArgType
+	StringArgType
+	SelectorArgType
+	StringListArgType
+)
+
+// ArgDef is a specification of a named argument used to register many
+// arguments at once
+type ArgDef struct {
+	Name, ShortName string
+	Description     string
+	Required        bool
+	Type            ArgType
+
+	// Default is the default value of the argument.  Its type must match the
+	// type of the argument (eg. `int` for an `IntArgType`).  It is ignored if it
+	// is `nil`.
+	Default interface{}
+
+	// PossibleValues are the possible values of a selector argument
+	PossibleValues []string
+}
+
+// AddFlags adds flags to the command in the order they are specified.  Any
+// flags which cannot be added are skipped and their errors are returned
+// together as a `ConfigErrors`.
+func (c *Command) AddFlags(defs []FlagDef) error {
+	var errs ConfigErrors
+
+	for _, def := range defs {
+		if err := c.checkFlag(def.Name, def.ShortName); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		c.AddFlag(def.Name, def.ShortName, def.Description)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// AddArgs adds named arguments to the command in the order they are specified.
+// Any arguments which cannot be added are skipped and their errors are returned
+// together as a `ConfigErrors`.
+func (c *Command) AddArgs(defs []ArgDef) error {
+	var errs ConfigErrors
+
+	for _, def := range defs {
+		if err := c.addArgDef(def); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// addArgDef adds a single argument from its specification
+func (c *Command) addArgDef(def ArgDef) error {
+	if err := c.checkArg(def.Name, def.ShortName); err != nil {
+		return err
+	}
+
+	// check the default value before registering the argument so that no
+	// argument is added if its definition is invalid
+	switch def.Type {
+	case IntArgType:
+		if _, ok := def.Default.(int); def.Default != nil && !ok {
+			return defaultTypeError(def, "int")
+		}
+	case FloatArgType:
+		if _, ok := def.Default.(float64); def.Default != nil && !ok {
+			return defaultTypeError(def, "float64")
+		}
+	case StringArgType:
+		if _, ok := def.Default.(string); def.Default != nil && !ok {
+			return defaultTypeError(def, "string")
+		}
+	case SelectorArgType:
+		if def.Default != nil {
+			v, ok := def.Default.(string)
+			if !ok {
+				return defaultTypeError(def, "string")
+			}
+
+			found := false
+			for _, pval := range def.PossibleValues {
+				if pval == v {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return fmt.Errorf("default value `%s` is not a possible value of argument `%s`", v, def.Name)
+			}
+		}
+	case StringListArgType:
+		if _, ok := def.Default.([]string); def.Default != nil && !ok {
+			return defaultTypeError(def, "[]string")
+		}
+	default:
+		return fmt.Errorf("unknown type for argument `%s`", def.Name)
+	}
+
+	switch def.Type {
+	case IntArgType:
+		ia := c.AddIntArg(def.Name, def.ShortName, def.Description, def.Required)
+		if def.Default != nil {
+			ia.SetDefaultValue(def.Default.(int))
+		}
+	case FloatArgType:
+		fa := c.AddFloatArg(def.Name, def.ShortName, def.Description, def.Required)
+		if def.Default != nil {
+			fa.SetDefaultValue(def.Default.(float64))
+		}
+	case StringArgType:
+		sa := c.AddStringArg(def.Name, def.ShortName, def.Description, def.Required)
+		if def.Default != nil {
+			sa.SetDefaultValue(def.Default.(string))
+		}
+	case SelectorArgType:
+		sea := c.AddSelectorArg(def.Name, def.ShortName, def.Description, def.Required, def.PossibleValues)
+		if def.Default != nil {
+			sea.SetDefaultValue(def.Default.(string))
+		}
+	case StringListArgType:
+		sla := c.AddStringListArg(def.Name, def.ShortName, def.Description, def.Required)
+		if def.Default != nil {
+			sla.SetDefaultValue(def.Default.([]string))
+		}
+	}
+
+	return nil
+}
+
+// defaultTypeError creates an error for a default value of the wrong type
+func defaultTypeError(def ArgDef, expected string) error {
+	return fmt.Errorf("default value of argument `%s` must be of type `%s` not `%T`", def.Name, expected, def.Default)
+}
@@ -0,0 +1,280 @@
+package olive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ConfigErrors is a collection of errors encountered while configuring a CLI
+type ConfigErrors []error
+
+func (ce ConfigErrors) Error() string {
+	msgs := make([]string, len(ce))
+	for i, err := range ce {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// ErrorKind classifies the errors caused by argument tokens
+type ErrorKind string
+
+// Enumeration of error kinds
+const (
+	KindUnknownFlag          ErrorKind = "unknown_flag"
+	KindUnknownArgument      ErrorKind = "unknown_argument"
+	KindUnknownSubcommand    ErrorKind = "unknown_subcommand"
+	KindUnexpectedSubcommand ErrorKind = "unexpected_subcommand"
+	KindMissingValue         ErrorKind = "missing_value"
+	KindInvalidValue         ErrorKind = "invalid_value"
+	KindDuplicate            ErrorKind = "duplicate"
+	KindConflict             ErrorKind = "conflict"
+)
+
+// kindError is an error produced while consuming a token along with its kind
+type kindError struct {
+	kind ErrorKind
+	err  error
+}
+
+// kindErrorf creates a new error of the given kind
+func kindErrorf(kind ErrorKind, format string, args ...interface{}) error {
+	return kindErrorOf(kind, fmt.Errorf(format, args...))
+}
+
+// kindErrorOf wraps an error with the given kind
+func kindErrorOf(kind ErrorKind, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+func (ke *kindError) Error() string {
+	return ke.err.Error()
+}
+
+func (ke *kindError) Unwrap() error {
+	return ke.err
+}
+
+// UnknownFlagError is an error caused by a flag which the CLI does not have
+type UnknownFlagError struct {
+	// Name is the name of the flag as given (without its leading dashes)
+	Name string
+
+	// ShortName indicates whether the flag was given by its short name
+	ShortName bool
+
+	// Suggestion is the name of a similarly named flag if there is one
+	Suggestion string
+}
+
+func (ufe *UnknownFlagError) Error() string {
+	return unknownNameMessage("flag", ufe.Name, ufe.ShortName, ufe.Suggestion)
+}
+
+// UnknownArgError is an error caused by a named argument which the CLI does not
+// have
+type UnknownArgError struct {
+	// Name is the name of the argument as given (without its leading dashes)
+	Name string
+
+	// ShortName indicates whether the argument was given by its short name
+	ShortName bool
+
+	// Suggestion is the name of a similarly named argument if there is one
+	Suggestion string
+}
+
+func (uae *UnknownArgError) Error() string {
+	return unknownNameMessage("argument", uae.Name, uae.ShortName, uae.Suggestion)
+}
+
+// unknownNameMessage creates the message of an unknown flag or argument error
+func unknownNameMessage(what, name string, shortName bool, suggestion string) string {
+	if shortName {
+		return fmt.Sprintf("unknown %s by short name: `%s`", what, name)
+	} else if suggestion != "" {
+		return fmt.Sprintf("unknown %s: `%s`, did you mean `--%s`?", what, name, suggestion)
+	}
+
+	return fmt.Sprintf("unknown %s: `%s`", what, name)
+}
+
+// ValidationError is an error caused by the validator of an argument rejecting
+// its value
+type ValidationError struct {
+	// Arg is the name of the argument
+	Arg string
+
+	// Value is the raw value which was rejected
+	Value string
+
+	// Err is the error returned by the validator
+	Err error
+}
+
+func (ve *ValidationError) Error() string {
+	return fmt.Sprintf("argument \"%s\" rejected value \"%s\": %s", ve.Arg, ve.Value, ve.Err.Error())
+}
+
+func (ve *ValidationError) Unwrap() error {
+	return ve.Err
+}
+
+// InvalidValueError is an error caused by a value which is not of the type of
+// its argument (eg. `abc` for an integer) or not one of its possible values (eg.
+// for a selector)
+type InvalidValueError struct {
+	// Arg is the name of the argument
+	Arg string
+
+	// Value is the raw value which was rejected
+	Value string
+
+	// Err describes why the value is invalid
+	Err error
+}
+
+func (ive *InvalidValueError) Error() string {
+	return ive.Err.Error()
+}
+
+func (ive *InvalidValueError) Unwrap() error {
+	return ive.Err
+}
+
+// RangeError is an error caused by a numeric value which lies outside the range
+// of its argument or of its type
+type RangeError struct {
+	// Arg is the name of the argument
+	Arg string
+
+	// Value is the value which was rejected
+	Value string
+
+	// Err describes the range which was exceeded
+	Err error
+}
+
+func (re *RangeError) Error() string {
+	return re.Err.Error()
+}
+
+func (re *RangeError) Unwrap() error {
+	return re.Err
+}
+
+// PathError is an error caused by the value of a path argument which is not a
+// valid path or which does not satisfy the constraints of the argument (eg. a
+// path which must exist)
+type PathError struct {
+	// Arg is the name of the argument
+	Arg string
+
+	// Value is the path as it was given
+	Value string
+
+	// Err describes the problem with the path and wraps any error returned by
+	// the file system
+	Err error
+}
+
+func (pe *PathError) Error() string {
+	return pe.Err.Error()
+}
+
+func (pe *PathError) Unwrap() error {
+	return pe.Err
+}
+
+// MissingSubcommandError is an error caused by a command which requires a
+// subcommand not receiving one
+type MissingSubcommandError struct {
+	// Command is the name of the command
+	Command string
+}
+
+func (mse *MissingSubcommandError) Error() string {
+	return fmt.Sprintf("`%s` requires a subcommand", mse.Command)
+}
+
+// MissingPrimaryArgError is an error caused by a required primary argument not
+// receiving a value
+type MissingPrimaryArgError struct {
+	// Command is the name of the command
+	Command string
+
+	// Arg is the name of the primary argument
+	Arg string
+}
+
+func (mpe *MissingPrimaryArgError) Error() string {
+	return fmt.Sprintf("command \"%s\" requires argument <%s>", mpe.Command, mpe.Arg)
+}
+
+// MissingRequiredError is an error caused by required arguments not receiving
+// values
+type MissingRequiredError struct {
+	// Name is the qualified name of the first missing argument (eg.
+	// `build.output`) which is reported in the message
+	Name string
+
+	// Missing is the qualified names of all the missing arguments in the order
+	// they are reported by `MissingRequired`
+	Missing []string
+}
+
+func (mre *MissingRequiredError) Error() string {
+	return fmt.Sprintf("missing required argument: `%s`", mre.Name)
+}
+
+// TokenError is an error caused by a specific argument token.  Errors returned
+// when parsing fails because of a token are of this type.
+type TokenError struct {
+	// Index is the position of the token in the full list of arguments
+	// including the application name (ie. its index in `os.Args`)
+	Index int
+
+	// Token is the argument token itself
+	Token string
+
+	// Kind is the kind of the error
+	Kind ErrorKind
+
+	// Err is the underlying error
+	Err error
+}
+
+// newTokenError creates a token error from an error produced while consuming
+// the token
+func newTokenError(index int, token string, err error) *TokenError {
+	te := &TokenError{Index: index, Token: token, Err: err}
+
+	var ke *kindError
+	if errors.As(err, &ke) {
+		te.Kind, te.Err = ke.kind, ke.err
+	}
+
+	return te
+}
+
+func (te *TokenError) Error() string {
+	return fmt.Sprintf("argument %d: %s", te.Index, te.Err.Error())
+}
+
+func (te *TokenError) Unwrap() error {
+	return te.Err
+}
+
+// MarshalJSON encodes the error as a JSON object for use by other tools (eg.
+// `{"error":"...","kind":"unknown_flag","token":"--bogus","index":3}`)
+func (te *TokenError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Error string    `json:"error"`
+		Kind  ErrorKind `json:"kind"`
+		Token string    `json:"token"`
+		Index int       `json:"index"`
+	}{te.Err.Error(), te.Kind, te.Token, te.Index})
+}
@@ -0,0 +1,97 @@
+package olive
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MarkMutuallyExclusive marks a group of flags and arguments of the command of
+// which at most one may be given (eg. `--json` and `--yaml`).  The names given
+// are checked by `Lint`.
+func (c *Command) MarkMutuallyExclusive(names ...string) {
+	c.exclusiveGroups = append(c.exclusiveGroups, names)
+}
+
+// MarkRequiredTogether marks a group of flags and arguments of the command
+// which must all be given if any one of them is given (eg. `--user` and
+// `--password`).  The names given are checked by `Lint`.
+func (c *Command) MarkRequiredTogether(names ...string) {
+	c.togetherGroups = append(c.togetherGroups, names)
+}
+
+// AddRequiredOneOf marks a group of flags and arguments of the command of which
+// exactly one must be given (eg. `--json` and `--yaml` to select a format).
+// The names given are checked by `Lint`.
+func (c *Command) AddRequiredOneOf(names ...string) {
+	c.oneOfGroups = append(c.oneOfGroups, names)
+}
+
+// groups returns all the relationship groups of the command
+func (c *Command) groups() [][]string {
+	groups := make([][]string, 0, len(c.exclusiveGroups)+len(c.togetherGroups)+len(c.oneOfGroups))
+	groups = append(groups, c.exclusiveGroups...)
+	groups = append(groups, c.togetherGroups...)
+	return append(groups, c.oneOfGroups...)
+}
+
+// checkGroups checks that the flags and arguments given satisfy the
+// relationship groups of each command on the stack.  Only the values given
+// explicitly are considered: defaults are not filled in yet.
+func (ap *argParser) checkGroups() error {
+	for i, c := range ap.commandStack {
+		for _, group := range c.exclusiveGroups {
+			if given := ap.givenNames(i, group); len(given) > 1 {
+				return fmt.Errorf("`--%s` and `--%s` cannot be given together", given[0], given[1])
+			}
+		}
+
+		for _, group := range c.oneOfGroups {
+			given := ap.givenNames(i, group)
+			if len(given) == 0 {
+				return fmt.Errorf("one of --%s is required", strings.Join(group, ", --"))
+			} else if len(given) > 1 {
+				return fmt.Errorf("`--%s` and `--%s` cannot be given together", given[0], given[1])
+			}
+		}
+
+		for _, group := range c.togetherGroups {
+			for _, name := range group {
+				if !ap.isGiven(i, name) {
+					continue
+				}
+
+				for _, other := range group {
+					if !ap.isGiven(i, other) {
+						return fmt.Errorf("`--%s` must be given together with `--%s`", name, other)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// givenNames returns the names of a group of flags and arguments of the command
+// at the given position on the stack which were given
+func (ap *argParser) givenNames(ndx int, group []string) []string {
+	var given []string
+	for _, name := range group {
+		if ap.isGiven(ndx, name) {
+			given = append(given, name)
+		}
+	}
+
+	return given
+}
+
+// isGiven checks whether a flag or argument of the command at the given
+// position on the stack was given
+func (ap *argParser) isGiven(ndx int, name string) bool {
+	if _, ok := ap.semanticStack[ndx].flags[name]; ok {
+		return true
+	}
+
+	_, ok := ap.semanticStack[ndx].Arguments[name]
+	return ok
+}
@@ -1,103 +1,327 @@
 package olive
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/eidolon/wordwrap"
 )
 
+// HelpText is the collection of literal text used in help messages.  It can be
+// overridden to translate help messages.
+type HelpText struct {
+	// Section headers
+	Usage, Commands, PrimaryArgument, Arguments, Flags string
+
+	// Command is the placeholder for a subcommand in the usage line
+	Command string
+
+	// HelpFlag is the description of the builtin help flag
+	HelpFlag string
+
+	// VersionFlag is the description of the builtin version flag
+	VersionFlag string
+
+	// DisableFlag is the format of the description of the disable flag of an
+	// argument.  It is given the name of the argument.
+	DisableFlag string
+
+	// Example is the prefix of the example value of an argument
+	Example string
+
+	// Default is the label of the default value of an argument
+	Default string
+}
+
+// DefaultHelpText is the text used in help messages by default
+var DefaultHelpText = HelpText{
+	Usage:           "Usage",
+	Commands:        "Commands",
+	PrimaryArgument: "Primary Argument",
+	Arguments:       "Arguments",
+	Flags:           "Flags",
+	Command:         "command",
+	HelpFlag:        "Get help",
+	VersionFlag:     "Display the version",
+	DisableFlag:     "Disable --%s",
+	Example:         "e.g.",
+	Default:         "default",
+}
+
+// helpText returns the help text of the CLI the command belongs to
+func (c *Command) helpText() *HelpText {
+	if ht := c.root().HelpText; ht != nil {
+		return ht
+	}
+
+	return &DefaultHelpText
+}
+
+// flagDescription returns the description of a flag of the command.  The
+// descriptions of the builtin help and version flags and of disable flags are
+// taken from the help text.
+func (c *Command) flagDescription(flag *Flag) string {
+	if flag.isHelp() {
+		return c.helpText().HelpFlag
+	}
+
+	if flag.isVersion() {
+		return c.helpText().VersionFlag
+	}
+
+	if flag.disables != "" {
+		return fmt.Sprintf(c.helpText().DisableFlag, flag.disables)
+	}
+
+	return flag.desc
+}
+
+// helpWriter is the destination of a help message being built
+type helpWriter interface {
+	WriteString(string) (int, error)
+	WriteRune(rune) (int, error)
+}
+
 // helpBuilder is a type used to build help messages
 type helpBuilder struct {
 	c *Command
-	b strings.Builder
+	b helpWriter
 	w wordwrap.WrapperFunc
+
+	// res is the result of parsing the command so far if help was requested
+	// during parsing.  It is used to determine which subcommands are available.
+	res *ArgParseResult
+}
+
+// getHelpMessage generates a help message for a given command.  `res` is the
+// result of the command accumulated so far if help is displayed during parsing
+// and may be `nil`.
+func getHelpMessage(c *Command, res *ArgParseResult) string {
+	b := &strings.Builder{}
+	newHelpBuilder(c, b, res).buildMessage()
+	return b.String()
 }
 
-// getHelpMessage generates a help message for a given command
-func getHelpMessage(c *Command) string {
-	hb := &helpBuilder{
-		c: c,
-		b: strings.Builder{},
-		w: wordwrap.Wrapper(60, false),
+// writeHelpMessage writes the help message for a given command to a writer as
+// it is generated.  `res` is used as it is in `getHelpMessage`.
+func writeHelpMessage(w io.Writer, c *Command, res *ArgParseResult) error {
+	bw := bufio.NewWriter(w)
+	newHelpBuilder(c, bw, res).buildMessage()
+
+	// write errors are sticky so any error is reported by the flush
+	return bw.Flush()
+}
+
+// getUsageLine generates only the usage line of the help message for a given
+// command.  `res` is used as it is in `getHelpMessage`.
+func getUsageLine(c *Command, res *ArgParseResult) string {
+	b := &strings.Builder{}
+	newHelpBuilder(c, b, res).buildUsageLine()
+
+	// the indentation leaves trailing whitespace after the line
+	return strings.TrimRight(b.String(), " \n") + "\n"
+}
+
+// newHelpBuilder creates a new help builder for a command writing to `b`
+func newHelpBuilder(c *Command, b helpWriter, res *ArgParseResult) *helpBuilder {
+	return &helpBuilder{
+		c:   c,
+		b:   b,
+		w:   wordwrap.Wrapper(60, false),
+		res: res,
+	}
+}
+
+// displayedSubcommands returns all the subcommands of the command that should
+// be displayed in its help message
+func (hb *helpBuilder) displayedSubcommands() []*Command {
+	var subcs []*Command
+	for _, subc := range hb.c.subcommands {
+		if subc.available(hb.res) {
+			subcs = append(subcs, subc)
+		}
+	}
+
+	return subcs
+}
+
+// displayedArgs returns all the arguments of the command that should be
+// displayed in its help message
+func (hb *helpBuilder) displayedArgs() []Argument {
+	var args []Argument
+	for _, arg := range hb.c.args {
+		if arg.Enabled() && !arg.base().hidden {
+			args = append(args, arg)
+		}
+	}
+
+	return args
+}
+
+// displayedFlags returns all the flags of the command that should be displayed
+// in its help message
+func (hb *helpBuilder) displayedFlags() []*Flag {
+	var flags []*Flag
+	for _, flag := range hb.c.flags {
+		if flag.Enabled() && !flag.hidden {
+			flags = append(flags, flag)
+		}
+	}
+
+	return flags
+}
+
+// valueHint returns the hint for the value of an argument displayed in the
+// usage line
+func valueHint(arg Argument) string {
+	switch v := arg.(type) {
+	case *IntArgument:
+		return "int"
+	case *FloatArgument:
+		return "float"
+	case *BoolArgument:
+		return "bool"
+	case *StringArgument:
+		return "string"
+	case *PathArgument:
+		return "path"
+	case *StringListArgument:
+		return "string,..."
+	case *IntListArgument:
+		return "int"
+	case *SelectorArgument:
+		return strings.Join(v.values, "|")
+	case *TriStateArgument:
+		return strings.Join(triStateNames, "|")
+	case *PatternSelectorArgument:
+		return v.pattern
+	case *ChoiceArgument:
+		return strings.Join(v.keys, "|")
+	}
+
+	return ""
+}
+
+// defaultHint returns the default value of an argument as displayed in help if
+// it has one
+func defaultHint(arg Argument) (string, bool) {
+	val, ok := arg.GetDefaultValue()
+	if !ok {
+		return "", false
+	}
+
+	// choices are displayed by their names
+	if ca, ok := arg.(*ChoiceArgument); ok {
+		return ca.keyOf(val)
+	}
+
+	switch v := val.(type) {
+	case string:
+		if v == "" {
+			return `""`, true
+		}
+	case []int:
+		elems := make([]string, len(v))
+		for i, elem := range v {
+			elems[i] = strconv.Itoa(elem)
+		}
+
+		return strings.Join(elems, ","), true
 	}
 
-	return hb.buildMessage()
+	return formatValue(val), true
 }
 
 // -----------------------------------------------------------------------------
 
-func (hb *helpBuilder) buildMessage() string {
+func (hb *helpBuilder) buildMessage() {
+	if hb.c.helpHeader != "" {
+		hb.b.WriteString(hb.w(hb.c.helpHeader))
+		hb.b.WriteString("\n\n")
+	}
+
 	hb.b.WriteString(hb.w(hb.c.Description))
-	hb.b.WriteString("\n\nUsage:\n\n")
+	hb.b.WriteString("\n\n" + hb.c.helpText().Usage + ":\n\n")
 
 	hb.buildUsageLine()
 
-	if len(hb.c.subcommands) > 0 {
-		hb.b.WriteString("\nCommands:\n\n")
+	if len(hb.displayedSubcommands()) > 0 {
+		hb.b.WriteString("\n" + hb.c.helpText().Commands + ":\n\n")
 
 		hb.buildSubcommandsList()
 	}
 
 	if hb.c.primaryArg != nil {
-		hb.b.WriteString("\nPrimary Argument:\n\n")
+		hb.b.WriteString("\n" + hb.c.helpText().PrimaryArgument + ":\n\n")
 
 		hb.b.WriteString(wordwrap.Indent(
 			fmt.Sprintf("%s   %s", hb.c.primaryArg.name, hb.c.primaryArg.desc), "    ", false),
 		)
 	}
 
-	if len(hb.c.args) > 0 {
-		hb.b.WriteString("\nArguments:\n\n")
+	if len(hb.displayedArgs()) > 0 {
+		hb.b.WriteString("\n" + hb.c.helpText().Arguments + ":\n\n")
 
 		hb.buildArgumentsList()
 	}
 
-	if len(hb.c.flags) > 0 {
-		hb.b.WriteString("\nFlags:\n\n")
+	if len(hb.displayedFlags()) > 0 {
+		hb.b.WriteString("\n" + hb.c.helpText().Flags + ":\n\n")
 
 		hb.buildFlagsList()
 	}
 
-	return hb.b.String()
+	if hb.c.helpFooter != "" {
+		hb.b.WriteString("\n")
+		hb.b.WriteString(hb.w(hb.c.helpFooter))
+		hb.b.WriteString("\n")
+	}
 }
 
 func (hb *helpBuilder) buildUsageLine() {
 	ub := strings.Builder{}
 
-	ub.WriteString(hb.c.Name + " ")
+	ub.WriteString(hb.c.usageName(hb.res) + " ")
 
-	if len(hb.c.subcommands) > 0 {
-		ub.WriteString("<command> ")
+	if len(hb.displayedSubcommands()) > 0 {
+		ub.WriteString("<" + hb.c.helpText().Command + "> ")
+	} else if hb.c.primaryArg != nil && hb.c.primaryArg.variadic {
+		ub.WriteString(fmt.Sprintf("[%s...] ", hb.c.primaryArg.name))
 	} else if hb.c.primaryArg != nil {
 		ub.WriteString(fmt.Sprintf("[%s] ", hb.c.primaryArg.name))
 	}
 
-	for _, arg := range hb.c.args {
-		var argValue string
-
-		switch v := arg.(type) {
-		case *IntArgument:
-			argValue = "int"
-		case *FloatArgument:
-			argValue = "float"
-		case *StringArgument:
-			argValue = "string"
-		case *SelectorArgument:
-			vnamesB := strings.Builder{}
-			for value := range v.possibleValues {
-				vnamesB.WriteString(value)
-				vnamesB.WriteRune('|')
-			}
+	for _, arg := range hb.displayedArgs() {
+		value := fmt.Sprintf("%s<%s>", hb.c.root().assignSep, valueHint(arg))
+		if arg.base().hasImpliedValue {
+			value = "[" + value + "]"
+		}
 
-			argValue = vnamesB.String()[:vnamesB.Len()-1]
+		// integer lists are collected by repeating the argument
+		if _, ok := arg.(*IntListArgument); ok {
+			value += "..."
 		}
 
-		ub.WriteString(fmt.Sprintf("[-%s|--%s=<%s>] ", arg.ShortName(), arg.Name(), argValue))
+		ub.WriteString(fmt.Sprintf("[-%s|--%s%s] ", arg.ShortName(), arg.Name(), value))
 	}
 
-	for _, flag := range hb.c.flags {
-		ub.WriteString(fmt.Sprintf("[-%s|--%s] ", flag.shortName, flag.name))
+	var group []string
+	for _, flag := range hb.displayedFlags() {
+		if hb.c.root().GroupShortFlags && utf8.RuneCountInString(flag.shortName) == 1 {
+			group = append(group, flag.shortName)
+		} else {
+			ub.WriteString(fmt.Sprintf("[-%s|--%s] ", flag.shortName, flag.usageName()))
+		}
+	}
+
+	if len(group) > 0 {
+		sort.Strings(group)
+		ub.WriteString(fmt.Sprintf("[-%s] ", strings.Join(group, "")))
 	}
 
 	ub.WriteRune('\n')
@@ -107,9 +331,9 @@ func (hb *helpBuilder) buildUsageLine() {
 
 func (hb *helpBuilder) buildSubcommandsList() {
 	maxCmdNameColLength := 0
-	for cmdName := range hb.c.subcommands {
-		if len(cmdName) > maxCmdNameColLength {
-			maxCmdNameColLength = len(cmdName)
+	for _, cmd := range hb.displayedSubcommands() {
+		if len(cmd.Name) > maxCmdNameColLength {
+			maxCmdNameColLength = len(cmd.Name)
 		}
 	}
 
@@ -117,9 +341,9 @@ func (hb *helpBuilder) buildSubcommandsList() {
 	maxCmdNameColLength += 3
 
 	// 4 spaces to the left
-	wdesc := wordwrap.Wrapper(60-maxCmdNameColLength-4, false)
+	wdesc := wordwrap.Wrapper(descWidth(maxCmdNameColLength+4), false)
 
-	for _, cmd := range hb.c.subcommands {
+	for _, cmd := range hb.displayedSubcommands() {
 		hb.b.WriteString(wordwrap.Indent(
 			wdesc(cmd.Description),
 			"    "+cmd.Name+strings.Repeat(" ", maxCmdNameColLength-len(cmd.Name)),
@@ -131,69 +355,107 @@ func (hb *helpBuilder) buildSubcommandsList() {
 }
 
 func (hb *helpBuilder) buildArgumentsList() {
-	maxArgNameColLength := 0
-	maxShortNameLength := 0
-	for argName, arg := range hb.c.args {
-		if len(argName)+len(arg.ShortName()) > maxArgNameColLength {
-			maxArgNameColLength = len(argName) + len(arg.ShortName())
+	var entries []namedEntry
+	for _, arg := range hb.displayedArgs() {
+		entry := namedEntry{
+			name:      arg.Name(),
+			shortName: arg.ShortName(),
+			desc:      arg.Description(),
 		}
 
-		if len(arg.ShortName()) > maxShortNameLength {
-			maxShortNameLength = len(arg.ShortName())
+		if def, ok := defaultHint(arg); ok {
+			entry.desc = strings.TrimSpace(entry.desc + " (" + hb.c.helpText().Default + ": " + def + ")")
 		}
-	}
-
-	// one comma, one space, 3 dashes
-	maxArgNameColLength += 5
 
-	// 4 spaces to the left
-	wdesc := wordwrap.Wrapper(60-maxArgNameColLength-4, false)
+		if sea, ok := arg.(*SelectorArgument); ok {
+			for _, value := range sea.values {
+				if desc, ok := sea.valueDescs[value]; ok {
+					entry.valueDescs = append(entry.valueDescs, [2]string{value, desc})
+				}
+			}
+		}
 
-	for _, arg := range hb.c.args {
-		hb.b.WriteString(wordwrap.Indent(
-			wdesc(arg.Description()),
-			fmt.Sprintf(
-				"    -%s,%s --%s%s   ",
-				arg.ShortName(),
-				strings.Repeat(" ", maxShortNameLength-len(arg.ShortName())),
-				arg.Name(),
-				strings.Repeat(" ", maxArgNameColLength-len(arg.Name())-len(arg.ShortName())-5),
-			),
-			false,
-		))
+		if example := arg.base().example; example != "" {
+			entry.notes = append(entry.notes, fmt.Sprintf("%s --%s%s%s", hb.c.helpText().Example, arg.Name(), hb.c.root().assignSep, example))
+		}
 
-		hb.b.WriteRune('\n')
+		entries = append(entries, entry)
 	}
+
+	hb.buildNamedList(entries)
 }
 
 func (hb *helpBuilder) buildFlagsList() {
-	maxFlagNameColLength := 0
+	var entries []namedEntry
+	for _, flag := range hb.displayedFlags() {
+		entries = append(entries, namedEntry{
+			name:      flag.usageName(),
+			shortName: flag.shortName,
+			desc:      hb.c.flagDescription(flag),
+		})
+	}
+
+	hb.buildNamedList(entries)
+}
+
+// namedEntry is an entry in a list of named arguments or flags
+type namedEntry struct {
+	name, shortName string
+	desc            string
+
+	// notes are additional lines displayed beneath the description
+	notes []string
+
+	// valueDescs is a list of values and their descriptions which is
+	// displayed as a table beneath the description
+	valueDescs [][2]string
+}
+
+// buildNamedList builds a two column list of named entries in which the
+// descriptions (including their wrapped continuation lines) are all aligned in
+// the second column
+func (hb *helpBuilder) buildNamedList(entries []namedEntry) {
+	maxNameLength := 0
 	maxShortNameLength := 0
-	for flagName, flag := range hb.c.flags {
-		if len(flagName)+len(flag.shortName) > maxFlagNameColLength {
-			maxFlagNameColLength = len(flagName) + len(flag.shortName)
+	for _, entry := range entries {
+		if len(entry.name) > maxNameLength {
+			maxNameLength = len(entry.name)
 		}
 
-		if len(flag.shortName) > maxShortNameLength {
-			maxShortNameLength = len(flag.shortName)
+		if len(entry.shortName) > maxShortNameLength {
+			maxShortNameLength = len(entry.shortName)
 		}
 	}
 
-	// one comma, one space, 3 dashes
-	maxFlagNameColLength += 5
+	// 4 spaces to the left, 3 dashes, one comma, one space, 3 spaces to the
+	// right
+	nameColLength := maxShortNameLength + maxNameLength + 12
 
-	// 4 spaces to the left
-	wdesc := wordwrap.Wrapper(60-maxFlagNameColLength-4, false)
+	wdesc := wordwrap.Wrapper(descWidth(nameColLength), false)
+
+	for _, entry := range entries {
+		// each note is wrapped separately so it always begins on its own line
+		lines := []string{wdesc(entry.desc)}
+		for _, note := range entry.notes {
+			lines = append(lines, wdesc(note))
+		}
+
+		if len(entry.valueDescs) > 0 {
+			lines = append(lines, hb.buildValueTable(entry.valueDescs, descWidth(nameColLength)))
+		}
+
+		if entry.desc == "" && len(lines) > 1 {
+			lines = lines[1:]
+		}
 
-	for _, flag := range hb.c.flags {
 		hb.b.WriteString(wordwrap.Indent(
-			wdesc(flag.desc),
+			strings.Join(lines, "\n"),
 			fmt.Sprintf(
 				"    -%s,%s --%s%s   ",
-				flag.shortName,
-				strings.Repeat(" ", maxShortNameLength-len(flag.shortName)),
-				flag.name,
-				strings.Repeat(" ", maxFlagNameColLength-len(flag.name)-len(flag.shortName)-5),
+				entry.shortName,
+				strings.Repeat(" ", maxShortNameLength-len(entry.shortName)),
+				entry.name,
+				strings.Repeat(" ", maxNameLength-len(entry.name)),
 			),
 			false,
 		))
@@ -201,3 +463,45 @@ func (hb *helpBuilder) buildFlagsList() {
 		hb.b.WriteRune('\n')
 	}
 }
+
+// buildValueTable builds a table of values and their descriptions which fits
+// within the given width
+func (hb *helpBuilder) buildValueTable(valueDescs [][2]string, width int) string {
+	maxValueLength := 0
+	for _, vd := range valueDescs {
+		if len(vd[0]) > maxValueLength {
+			maxValueLength = len(vd[0])
+		}
+	}
+
+	// 2 spaces to the left and right
+	valueDescWidth := width - maxValueLength - 4
+	if valueDescWidth < 20 {
+		valueDescWidth = 20
+	}
+
+	wdesc := wordwrap.Wrapper(valueDescWidth, false)
+
+	rows := make([]string, len(valueDescs))
+	for i, vd := range valueDescs {
+		rows[i] = wordwrap.Indent(
+			wdesc(vd[1]),
+			"  "+vd[0]+strings.Repeat(" ", maxValueLength-len(vd[0]))+"  ",
+			false,
+		)
+	}
+
+	return strings.Join(rows, "\n")
+}
+
+// descWidth returns the width descriptions are wrapped to given the width of
+// the column to their left
+func descWidth(colLength int) int {
+	// always leave a reasonable amount of room for descriptions even if the
+	// names are very long
+	if 60-colLength < 20 {
+		return 20
+	}
+
+	return 60 - colLength
+}
@@ -0,0 +1,155 @@
+package olive
+
+import "encoding/json"
+
+// commandJSON is the JSON representation of a command in the help tree
+type commandJSON struct {
+	Name               string          `json:"name"`
+	Description        string          `json:"description"`
+	RequiresSubcommand bool            `json:"requiresSubcommand"`
+	PrimaryArgument    *primaryArgJSON `json:"primaryArgument,omitempty"`
+	Arguments          []argumentJSON  `json:"arguments"`
+	Flags              []flagJSON      `json:"flags"`
+	Subcommands        []commandJSON   `json:"subcommands"`
+}
+
+// primaryArgJSON is the JSON representation of a primary argument
+type primaryArgJSON struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	Variadic    bool   `json:"variadic,omitempty"`
+}
+
+// argumentJSON is the JSON representation of a named argument
+type argumentJSON struct {
+	Name           string      `json:"name"`
+	ShortName      string      `json:"shortName"`
+	Description    string      `json:"description"`
+	Type           string      `json:"type"`
+	Required       bool        `json:"required"`
+	Default        interface{} `json:"default,omitempty"`
+	PossibleValues []string    `json:"possibleValues,omitempty"`
+	Pattern        string      `json:"pattern,omitempty"`
+
+	ValueDescriptions map[string]string `json:"valueDescriptions,omitempty"`
+}
+
+// flagJSON is the JSON representation of a flag
+type flagJSON struct {
+	Name        string `json:"name"`
+	ShortName   string `json:"shortName"`
+	Description string `json:"description"`
+	Negatable   bool   `json:"negatable,omitempty"`
+}
+
+// HelpJSON returns a JSON description of the command and all of its
+// subcommands for use by other tools.  Disabled and hidden flags and arguments
+// are omitted.
+func (c *Command) HelpJSON() ([]byte, error) {
+	return json.Marshal(newCommandJSON(c))
+}
+
+// newCommandJSON converts a command into its JSON representation
+func newCommandJSON(c *Command) commandJSON {
+	cj := commandJSON{
+		Name:               c.Name,
+		Description:        c.Description,
+		RequiresSubcommand: len(c.subcommands) > 0 && c.RequiresSubcommand,
+		Arguments:          []argumentJSON{},
+		Flags:              []flagJSON{},
+		Subcommands:        []commandJSON{},
+	}
+
+	if pa, ok := c.PrimaryArgument(); ok {
+		cj.PrimaryArgument = &primaryArgJSON{
+			Name:        pa.Name(),
+			Description: pa.Description(),
+			Required:    pa.Required(),
+			Variadic:    pa.Variadic(),
+		}
+	}
+
+	for _, arg := range c.Arguments() {
+		if !arg.Enabled() || arg.base().hidden {
+			continue
+		}
+
+		aj := argumentJSON{
+			Name:        arg.Name(),
+			ShortName:   arg.ShortName(),
+			Description: arg.Description(),
+			Type:        argTypeName(arg),
+			Required:    arg.Required(),
+		}
+
+		if val, ok := arg.GetDefaultValue(); ok {
+			aj.Default = val
+		}
+
+		if sea, ok := arg.(*SelectorArgument); ok {
+			aj.PossibleValues = sea.PossibleValues()
+			aj.ValueDescriptions = sea.ValueDescriptions()
+		} else if _, ok := arg.(*TriStateArgument); ok {
+			aj.PossibleValues = triStateNames
+		} else if psa, ok := arg.(*PatternSelectorArgument); ok {
+			aj.Pattern = psa.Pattern()
+		} else if ca, ok := arg.(*ChoiceArgument); ok {
+			aj.PossibleValues = ca.Choices()
+
+			// the default is displayed as the name of its choice
+			if aj.Default != nil {
+				aj.Default, _ = ca.keyOf(aj.Default)
+			}
+		}
+
+		cj.Arguments = append(cj.Arguments, aj)
+	}
+
+	for _, flag := range c.Flags() {
+		if flag.Enabled() && !flag.hidden {
+			cj.Flags = append(cj.Flags, flagJSON{
+				Name:        flag.Name(),
+				ShortName:   flag.ShortName(),
+				Description: c.flagDescription(flag),
+				Negatable:   flag.negatable,
+			})
+		}
+	}
+
+	for _, subc := range c.Subcommands() {
+		cj.Subcommands = append(cj.Subcommands, newCommandJSON(subc))
+	}
+
+	return cj
+}
+
+// argTypeName returns the name of the type of an argument
+func argTypeName(arg Argument) string {
+	switch arg.(type) {
+	case *IntArgument:
+		return "int"
+	case *FloatArgument:
+		return "float"
+	case *BoolArgument:
+		return "bool"
+	case *StringArgument:
+		return "string"
+	case *PathArgument:
+		return "path"
+	case *StringListArgument:
+		return "string-list"
+	case *IntListArgument:
+		return "int-list"
+	case *SelectorArgument:
+		return "selector"
+	case *TriStateArgument:
+		return "tri-state"
+	case *PatternSelectorArgument:
+		return "pattern"
+	case *ChoiceArgument:
+		return "choice"
+	}
+
+	return ""
+}
@@ -0,0 +1,163 @@
+package olive
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Lint checks the CLI for definitions which are valid but likely to confuse its
+// users.  The command and all of its subcommands are checked.  It returns an
+// error describing each problem found.
+func (c *Command) Lint() []error {
+	var errs []error
+
+	for _, check := range lintChecks {
+		errs = append(errs, check(c)...)
+	}
+
+	for _, subc := range c.Subcommands() {
+		errs = append(errs, subc.Lint()...)
+	}
+
+	return errs
+}
+
+// lintChecks is the checks run by `Lint` on each command
+var lintChecks = []func(*Command) []error{
+	lintShortNamePrefixes,
+	lintRejectedSelectors,
+	lintDescriptions,
+	lintRequiredDefaults,
+	lintGroupNames,
+}
+
+// lintShortNamePrefixes reports pairs of short names of flags and arguments of
+// the same command where one is a prefix of the other (eg. `-i` and `-int`) or
+// which are shared by a flag and an argument.  Options without a short name are
+// ignored.
+func lintShortNamePrefixes(c *Command) []error {
+	var names []string
+	for name := range c.flagsByShortName {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	for name := range c.argsByShortName {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	var errs []error
+	for i, name := range names {
+		for _, other := range names[i+1:] {
+			// the names of flags and arguments are each unique so a repeated
+			// name is shared by a flag and an argument
+			if name == other {
+				errs = append(errs, fmt.Errorf("short name `%s` of command `%s` is ambiguous: it is used by both a flag and an argument", name, c.Name))
+			} else if strings.HasPrefix(other, name) {
+				errs = append(errs, fmt.Errorf("short names `%s` and `%s` of command `%s` are ambiguous: one is a prefix of the other", name, other, c.Name))
+			}
+		}
+	}
+
+	return errs
+}
+
+// lintRejectedSelectors reports selector arguments whose validator rejects every
+// one of their possible values so they can never receive a valid value
+func lintRejectedSelectors(c *Command) []error {
+	var errs []error
+
+	for _, arg := range c.Arguments() {
+		sea, ok := arg.(*SelectorArgument)
+		if !ok || sea.validator == nil {
+			continue
+		}
+
+		accepted := false
+		for _, value := range sea.values {
+			if sea.validator(value) == nil {
+				accepted = true
+				break
+			}
+		}
+
+		if !accepted {
+			errs = append(errs, fmt.Errorf("selector argument `%s` of command `%s` rejects all of its possible values", sea.name, c.Name))
+		}
+	}
+
+	return errs
+}
+
+// lintDescriptions reports flags, arguments and subcommands of the command
+// without a description if the CLI requires descriptions
+func lintDescriptions(c *Command) []error {
+	if !c.root().RequireDescriptions {
+		return nil
+	}
+
+	var errs []error
+
+	for _, subc := range c.Subcommands() {
+		if subc.Description == "" {
+			errs = append(errs, fmt.Errorf("subcommand `%s` of command `%s` has no description", subc.Name, c.Name))
+		}
+	}
+
+	if c.primaryArg != nil && c.primaryArg.desc == "" {
+		errs = append(errs, fmt.Errorf("primary argument `%s` of command `%s` has no description", c.primaryArg.name, c.Name))
+	}
+
+	for _, arg := range c.Arguments() {
+		if arg.Description() == "" {
+			errs = append(errs, fmt.Errorf("argument `%s` of command `%s` has no description", arg.Name(), c.Name))
+		}
+	}
+
+	for _, flag := range c.Flags() {
+		if flag.desc == "" {
+			errs = append(errs, fmt.Errorf("flag `%s` of command `%s` has no description", flag.name, c.Name))
+		}
+	}
+
+	return errs
+}
+
+// lintRequiredDefaults reports required arguments which also have a default
+// value since the default value means that they can never be missing
+func lintRequiredDefaults(c *Command) []error {
+	var errs []error
+
+	for _, arg := range c.Arguments() {
+		if _, ok := arg.GetDefaultValue(); ok && arg.Required() && !arg.base().defaultDisplayOnly {
+			errs = append(errs, fmt.Errorf("argument `%s` of command `%s` is required but has a default value so it can never be missing", arg.Name(), c.Name))
+		}
+	}
+
+	return errs
+}
+
+// lintGroupNames reports names in the relationship groups of the command which
+// are not the names of any of its flags or arguments
+func lintGroupNames(c *Command) []error {
+	var errs []error
+
+	for _, group := range c.groups() {
+		for _, name := range group {
+			_, isFlag := c.flags[name]
+			_, isArg := c.args[name]
+
+			if !isFlag && !isArg {
+				errs = append(errs, fmt.Errorf("group of command `%s` references unknown flag or argument `%s`", c.Name, name))
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,116 @@
+package olive
+
+import "fmt"
+
+// Merge grafts the subcommands, flags, and named arguments of another command
+// onto this command.  Subcommands with the same name are merged recursively.
+// If any of the definitions collide, an error is returned and this command is
+// left unchanged.  A subcommand merged with a subcommand of the same name is
+// only available if the availability predicates of both allow it.  The definitions are shared rather than copied so `other`
+// should not be modified after it is merged.
+func (c *Command) Merge(other *Command) error {
+	if err := c.checkMerge(other); err != nil {
+		return err
+	}
+
+	c.merge(other)
+	return nil
+}
+
+// checkMerge checks whether another command can be merged into this command
+func (c *Command) checkMerge(other *Command) error {
+	for _, flag := range other.flags {
+		if c.hasHelpFlag() && flag.isHelp() {
+			continue
+		}
+
+		if err := c.checkFlag(flag.name, flag.shortName); err != nil {
+			return fmt.Errorf("cannot merge into `%s`: %s", c.Name, err.Error())
+		}
+	}
+
+	for _, arg := range other.args {
+		if err := c.checkArg(arg.Name(), arg.ShortName()); err != nil {
+			return fmt.Errorf("cannot merge into `%s`: %s", c.Name, err.Error())
+		}
+	}
+
+	if other.primaryArg != nil {
+		if c.primaryArg != nil {
+			return fmt.Errorf("cannot merge into `%s`: multiple primary arguments", c.Name)
+		}
+
+		if len(c.subcommands) > 0 {
+			return fmt.Errorf("cannot merge into `%s`: command cannot both take a primary argument and have subcommands", c.Name)
+		}
+	}
+
+	if len(other.subcommands) > 0 && c.primaryArg != nil {
+		return fmt.Errorf("cannot merge into `%s`: command cannot both take a primary argument and have subcommands", c.Name)
+	}
+
+	for name, osubc := range other.subcommands {
+		if subc, ok := c.subcommands[name]; ok {
+			if err := subc.checkMerge(osubc); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// merge merges another command into this command without checking for
+// collisions
+func (c *Command) merge(other *Command) {
+	// a merged subcommand is only available if both of its definitions allow it
+	if ofn := other.availableIf; ofn != nil {
+		if fn := c.availableIf; fn != nil {
+			c.availableIf = func(res *ArgParseResult) bool {
+				return fn(res) && ofn(res)
+			}
+		} else {
+			c.availableIf = ofn
+		}
+	}
+
+	hasHelp := c.hasHelpFlag()
+	for name, flag := range other.flags {
+		if hasHelp && flag.isHelp() {
+			continue
+		}
+
+		c.flags[name] = flag
+		c.flagsByShortName[flag.shortName] = flag
+	}
+
+	for name, arg := range other.args {
+		c.args[name] = arg
+		c.argsByShortName[arg.ShortName()] = arg
+		arg.base().command = c
+	}
+
+	if other.primaryArg != nil {
+		c.primaryArg = other.primaryArg
+	}
+
+	c.bindings = append(c.bindings, other.bindings...)
+	c.exclusiveGroups = append(c.exclusiveGroups, other.exclusiveGroups...)
+	c.togetherGroups = append(c.togetherGroups, other.togetherGroups...)
+	c.oneOfGroups = append(c.oneOfGroups, other.oneOfGroups...)
+
+	for name, osubc := range other.subcommands {
+		if subc, ok := c.subcommands[name]; ok {
+			subc.merge(osubc)
+		} else {
+			osubc.parent = c
+			c.subcommands[name] = osubc
+		}
+	}
+}
+
+// hasHelpFlag checks whether the command has the builtin help flag
+func (c *Command) hasHelpFlag() bool {
+	flag, ok := c.flags["help"]
+	return ok && flag.isHelp()
+}
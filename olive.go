@@ -1,9 +1,17 @@
 package olive
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
-	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // This file outlines the user-facing API of Olive.
@@ -24,6 +32,12 @@ type Command struct {
 	// be satisfied without one
 	RequiresSubcommand bool
 
+	// CollectTrailingArgs indicates that once the primary argument of this
+	// command has been supplied, all remaining arguments should be collected
+	// verbatim as trailing arguments instead of being parsed (eg. for `olive
+	// run <script> args...`)
+	CollectTrailingArgs bool
+
 	// All valid subcommands of this command organized by name.  The flag
 	// indicates whether or not a subcommand must be provided.
 	subcommands map[string]*Command
@@ -39,19 +53,193 @@ type Command struct {
 
 	// There can only be one primary argument per command
 	primaryArg *PrimaryArgument
+
+	// PromptForMissing indicates whether or not the user should be prompted
+	// interactively for required arguments that were not supplied.  This is
+	// only consulted on the initial command of the CLI.
+	PromptForMissing bool
+
+	// Prompter is used to request missing required arguments when
+	// PromptForMissing is enabled.  If it is `nil`, the user is only prompted
+	// if standard input is a terminal.
+	Prompter Prompter
+
+	// WarningWriter is where all warnings emitted by Olive are written.  It
+	// defaults to `os.Stderr` and can be set to `io.Discard` to silence
+	// warnings.  This is only consulted on the initial command of the CLI.
+	WarningWriter io.Writer
+
+	// CaseInsensitiveNames indicates whether or not the names of flags,
+	// arguments and subcommands are matched ignoring case (eg. `--Output` for
+	// `--output` or `BUILD` for `build`).  CaseInsensitiveShortNames does the
+	// same for short names.  A name given is always matched exactly against
+	// every command on the stack before it is matched ignoring case so short
+	// names of a single character can still differ by case (eg. `-v` and
+	// `-V`).  They must be set before the CLI is defined since names which
+	// differ only by case are rejected when they are added.  These are only
+	// consulted on the initial command of the CLI.
+	CaseInsensitiveNames      bool
+	CaseInsensitiveShortNames bool
+
+	// HelpOnNoArgs indicates whether or not help should be displayed and the
+	// application exited when no arguments are given to a CLI which requires a
+	// subcommand instead of reporting the missing subcommand.  This is only
+	// consulted on the initial command of the CLI.
+	HelpOnNoArgs bool
+
+	// RequireDescriptions indicates whether or not `Lint` should report flags,
+	// arguments and subcommands without a description.  This is only
+	// consulted on the initial command of the CLI.
+	RequireDescriptions bool
+
+	// GroupShortFlags indicates whether or not the flags with single character
+	// short names are collapsed into a single group (eg. `[-abc]`) in the usage
+	// line.  This is only consulted on the initial command of the CLI.
+	GroupShortFlags bool
+
+	// HelpText is the text used for the labels of help messages.  If it is
+	// `nil`, `DefaultHelpText` is used.  This is only consulted on the initial
+	// command of the CLI.
+	HelpText *HelpText
+
+	// ErrorWriter is where `Execute` writes errors along with usage.  It
+	// defaults to `os.Stderr`.  This is only consulted on the initial command
+	// of the CLI.
+	ErrorWriter io.Writer
+
+	// JSONErrors indicates whether or not `Execute` should write errors as JSON
+	// objects (eg. for use by editors) instead of as text along with usage.
+	// This is only consulted on the initial command of the CLI.
+	JSONErrors bool
+
+	// ParseErrorExitCode is the exit code used by `Execute` when the arguments
+	// could not be parsed.  It defaults to 2.  This is only consulted on the
+	// initial command of the CLI.
+	ParseErrorExitCode int
+
+	// RunErrorExitCode is the exit code used by `Execute` when the handler of
+	// the selected command returns an error.  It defaults to 1.  This is only
+	// consulted on the initial command of the CLI.
+	RunErrorExitCode int
+
+	// ExitFunc is called to exit the application (eg. after displaying help).
+	// If it is `nil`, `os.Exit` is used.  This is only consulted on the initial
+	// command of the CLI.
+	ExitFunc func(int)
+
+	// SuggestNames indicates whether or not unknown flags and arguments that
+	// are a near-miss of a known name should be reported along with the name
+	// that was likely intended.  This is only consulted on the initial command
+	// of the CLI.
+	SuggestNames bool
+
+	// AllowPrefixMatch indicates whether or not a long flag can be given by an
+	// unambiguous prefix of its name (eg. `--verb` for `--verbose`).  A prefix
+	// matching several flags is an error.  Hidden flags are never matched by a
+	// prefix.  This is only consulted on the initial command of the CLI.
+	AllowPrefixMatch bool
+
+	// UseInvokedName indicates whether or not the help and usage messages
+	// displayed while parsing should use the name the application was invoked
+	// with (the base name of the first argument passed to `ParseArgs`) instead
+	// of `Name`.  This is useful for applications that are invoked under
+	// multiple names.
+	UseInvokedName bool
+
+	// availableIf determines whether or not the command is available as a
+	// subcommand given the result of its parent command
+	availableIf func(*ArgParseResult) bool
+
+	// displayName is the name used to display the command in help and usage
+	displayName string
+
+	// helpHeader and helpFooter are displayed before and after the rest of the
+	// command's help message
+	helpHeader, helpFooter string
+
+	// assignSep is the separator between the name and value of an argument
+	assignSep string
+
+	// parent is the command this command is a subcommand of
+	parent *Command
+
+	// bindings is the functions which write the values of this command's
+	// result into bound variables after parsing
+	bindings []func(*ArgParseResult)
+
+	// handler is the function run by `Run` and `Execute` when this command is
+	// selected
+	handler func(context.Context, *ArgParseResult) error
+
+	// version is the version displayed by the version flag
+	version string
+
+	// exclusiveGroups, togetherGroups and oneOfGroups are the names of the
+	// flags and arguments marked mutually exclusive, required together and
+	// required one of respectively
+	exclusiveGroups [][]string
+	togetherGroups  [][]string
+	oneOfGroups     [][]string
 }
 
 // ArgParseResult is the result produced by the argument parser representing the
 // inputted arguments if parsing succeeded.
 type ArgParseResult struct {
+	// command is the command this is the result of
+	command *Command
+
 	flags map[string]struct{}
 
+	// counts is the number of times each countable flag was given
+	counts map[string]int
+
+	// negated is the names of the negatable flags which were negated
+	negated map[string]struct{}
+
 	Arguments map[string]interface{}
 
 	subcommandName string
 	subcommandRes  *ArgParseResult
 
+	// parent is the result of the parent command if this is the result of a
+	// subcommand
+	parent *ArgParseResult
+
 	primaryArg string
+
+	// primaryArgs is all the values of a variadic primary argument
+	primaryArgs []string
+
+	// halted indicates that an action stopped parsing in lenient mode before
+	// the result was complete
+	halted bool
+
+	// invokedName is the name the application was invoked with if this is the
+	// result of the initial command
+	invokedName string
+
+	// rawValues is the values of the arguments with a codec as they were given
+	// before they were decoded so that they can be rendered by `ToArgs`
+	rawValues map[string]string
+
+	trailingArgs []string
+
+	missingRequired []string
+
+	// provided is the names of the arguments whose values were provided by the
+	// user either as arguments or through prompts
+	provided map[string]struct{}
+
+	// lazyValues is the values of arguments with lazy validation whose
+	// validation has been deferred
+	lazyValues map[string]lazyValue
+}
+
+// lazyValue is the value of an argument whose validation has been deferred
+type lazyValue struct {
+	arg   Argument
+	raw   string
+	value interface{}
 }
 
 // -----------------------------------------------------------------------------
@@ -63,12 +251,86 @@ func NewCLI(name, desc string, helpEnabled bool) *Command {
 
 // ParseArgs parses the slice of arguments provided against a customized CLI. It
 // returns an ArgParseResult representing the accumulated result of parsing and
-// an error which will be `nil` if no error occured
-func ParseArgs(cli *Command, args []string) (*ArgParseResult, error) {
+// an error which will be `nil` if no error occured.  Options can be given to
+// change how the arguments are parsed.
+func ParseArgs(cli *Command, args []string, opts ...ParseOption) (*ArgParseResult, error) {
 	ap := &argParser{initialCommand: cli}
+	for _, opt := range opts {
+		opt(ap)
+	}
+
+	return ap.parseArgs(args)
+}
 
-	// trim off the first argument which is conventionally the application name
-	return ap.parse(args[1:])
+// ParseArgsInto parses arguments like `ParseArgs` on top of a base result (eg.
+// one loaded from a configuration file).  Any flags and arguments which are not
+// given take their values from the base result before default values are
+// applied.  The base result is not modified.
+func ParseArgsInto(cli *Command, args []string, base *ArgParseResult) (*ArgParseResult, error) {
+	ap := &argParser{initialCommand: cli, base: base}
+	return ap.parseArgs(args)
+}
+
+// LenientParse parses arguments like `ParseArgs` except that it never exits the
+// application.  Actions which would normally exit, such as displaying help,
+// instead stop parsing and return the result accumulated so far without an
+// error: use `Halted` on the result to detect this.  Otherwise, the result is
+// checked exactly as it is by `ParseArgs` (eg. missing required arguments are
+// still reported as a `MissingRequiredError`).  Note that `ParseArgs` never
+// calls `log.Fatalf` itself: errors in the definition of the CLI are reported
+// by the configuration methods when the CLI is defined and invalid default
+// values encountered while parsing are returned as errors.
+func LenientParse(cli *Command, args []string) (*ArgParseResult, error) {
+	ap := &argParser{initialCommand: cli, lenient: true}
+	return ap.parseArgs(args)
+}
+
+// ParseKnownArgs parses arguments like `ParseArgs` except that any flags,
+// arguments and other tokens which are not recognized are returned in order as
+// leftover arguments instead of causing an error (eg. so that a second parser
+// can handle them).  Other errors such as invalid values are still reported.
+func ParseKnownArgs(cli *Command, args []string) (*ArgParseResult, []string, error) {
+	ap := &argParser{initialCommand: cli, knownOnly: true}
+
+	result, err := ap.parseArgs(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, ap.leftover, nil
+}
+
+// DispatchByName parses arguments for a multi-call application where the name
+// the application was invoked by (`args[0]`) selects a subcommand of the CLI
+// (eg. with `ln` and `cp` both linked to the same binary).  A subcommand which
+// is not available is treated as unknown.  If the application was invoked by
+// the name of the CLI itself, the arguments are parsed normally.
+func (c *Command) DispatchByName(args []string) (*ArgParseResult, error) {
+	if len(args) == 0 {
+		return nil, errors.New("missing application name")
+	}
+
+	name := filepath.Base(args[0])
+	if name == c.Name {
+		return ParseArgs(c, args)
+	}
+
+	// the invoked name is the first token so availability is checked against
+	// the empty result of the CLI
+	if subc, ok := c.subcommands[name]; !ok || !subc.available(nil) {
+		return nil, fmt.Errorf("unknown command: `%s`", name)
+	}
+
+	// the invoked name is parsed as if it were a subcommand given explicitly
+	result, err := ParseArgs(c, append([]string{args[0], name}, args[1:]...))
+
+	// the positions of tokens must refer to the original arguments
+	var te *TokenError
+	if errors.As(err, &te) && te.Index > 1 {
+		te.Index--
+	}
+
+	return result, err
 }
 
 // -----------------------------------------------------------------------------
@@ -83,7 +345,16 @@ func (c *Command) AddSubcommand(name, desc string, helpEnabled bool) *Command {
 		log.Fatalf("multiple subcommands named `%s`", name)
 	}
 
+	if c.root().CaseInsensitiveNames {
+		for subName := range c.subcommands {
+			if strings.EqualFold(subName, name) {
+				log.Fatalf("subcommand name `%s` collides with `%s` ignoring case", name, subName)
+			}
+		}
+	}
+
 	subc := newCommand(name, desc, helpEnabled)
+	subc.parent = c
 
 	c.subcommands[name] = subc
 	return subc
@@ -99,14 +370,19 @@ func (c *Command) AddPrimaryArg(name, desc string, required bool) *PrimaryArgume
 	return c.primaryArg
 }
 
+// AddVariadicPrimaryArg adds a primary argument to the command which collects
+// all the positional values given (eg. for `rm file1 file2`).  The values are
+// accessed using `PrimaryArgs`.
+func (c *Command) AddVariadicPrimaryArg(name, desc string) *PrimaryArgument {
+	pa := c.AddPrimaryArg(name, desc, false)
+	pa.variadic = true
+	return pa
+}
+
 // AddFlag adds a flag to the command
 func (c *Command) AddFlag(name, shortName, desc string) *Flag {
-	if _, ok := c.flags[name]; ok {
-		log.Fatalf("multiple flags named `%s`\n", name)
-	}
-
-	if _, ok := c.flagsByShortName[shortName]; ok {
-		log.Fatalf("multiple flags with short name `%s`\n", shortName)
+	if err := c.checkFlag(name, shortName); err != nil {
+		log.Fatalf("%s\n", err.Error())
 	}
 
 	f := &Flag{
@@ -151,6 +427,38 @@ func (c *Command) AddFloatArg(name, shortName, desc string, required bool) *Floa
 	return fa
 }
 
+// AddBoolArg adds a named argument whose value is a boolean.  The values `true`,
+// `false`, `1`, `0`, `yes` and `no` among others are accepted.
+func (c *Command) AddBoolArg(name, shortName, desc string, required bool) *BoolArgument {
+	ba := &BoolArgument{
+		argumentBase: argumentBase{
+			name:      name,
+			shortName: shortName,
+			desc:      desc,
+			required:  required,
+		},
+	}
+
+	c.addArg(ba)
+	return ba
+}
+
+// AddPathArg adds a named argument whose value is a file system path.  The path
+// is stored as a cleaned absolute path.
+func (c *Command) AddPathArg(name, shortName, desc string, required bool) *PathArgument {
+	pa := &PathArgument{
+		argumentBase: argumentBase{
+			name:      name,
+			shortName: shortName,
+			desc:      desc,
+			required:  required,
+		},
+	}
+
+	c.addArg(pa)
+	return pa
+}
+
 // AddStringArg adds a named string argument
 func (c *Command) AddStringArg(name, shortName, desc string, required bool) *StringArgument {
 	sa := &StringArgument{
@@ -181,35 +489,315 @@ func (c *Command) AddSelectorArg(name, shortName, desc string, required bool, po
 			required:  required,
 		},
 		possibleValues: pvals,
+		values:         possibleValues,
 	}
 
 	c.addArg(sa)
 	return sa
 }
 
-// addArg adds an argument to a command
-func (c *Command) addArg(arg Argument) {
-	if _, ok := c.args[arg.Name()]; ok {
-		log.Fatalf("multiple arguments named `%s`", arg.Name())
+// AddPatternSelectorArg adds a named argument whose value must match a regular
+// expression.  The whole value must match the pattern.
+func (c *Command) AddPatternSelectorArg(name, shortName, desc string, required bool, pattern string) *PatternSelectorArgument {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		log.Fatalf("invalid pattern for argument `%s`: %s\n", name, err.Error())
+	}
+
+	psa := &PatternSelectorArgument{
+		argumentBase: argumentBase{
+			name:      name,
+			shortName: shortName,
+			desc:      desc,
+			required:  required,
+		},
+		pattern: pattern,
+		re:      re,
 	}
 
-	if _, ok := c.argsByShortName[arg.ShortName()]; ok {
-		log.Fatalf("multiple arguments with short name `%s`", arg.ShortName())
+	c.addArg(psa)
+	return psa
+}
+
+// AddChoiceArg adds a named argument whose value is one of the names of the
+// choices given.  The value stored in the result is the value the name maps to
+// (eg. `--level=info` might store a `LogLevel` constant).
+func (c *Command) AddChoiceArg(name, shortName, desc string, required bool, choices map[string]interface{}) *ChoiceArgument {
+	keys := make([]string, 0, len(choices))
+	for key := range choices {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ca := &ChoiceArgument{
+		argumentBase: argumentBase{
+			name:      name,
+			shortName: shortName,
+			desc:      desc,
+			required:  required,
+		},
+		choices: choices,
+		keys:    keys,
+	}
+
+	c.addArg(ca)
+	return ca
+}
+
+// AddTriStateArg adds a named argument whose value is one of `on`, `off` or
+// `auto` (eg. `--color=auto`)
+func (c *Command) AddTriStateArg(name, shortName, desc string, required bool) *TriStateArgument {
+	ta := &TriStateArgument{
+		argumentBase: argumentBase{
+			name:      name,
+			shortName: shortName,
+			desc:      desc,
+			required:  required,
+		},
+	}
+
+	c.addArg(ta)
+	return ta
+}
+
+// AddStringListArg adds a named argument whose value is a comma-separated list
+// of strings
+func (c *Command) AddStringListArg(name, shortName, desc string, required bool) *StringListArgument {
+	sla := &StringListArgument{
+		argumentBase: argumentBase{
+			name:      name,
+			shortName: shortName,
+			desc:      desc,
+			required:  required,
+		},
+	}
+
+	c.addArg(sla)
+	return sla
+}
+
+// AddIntListArg adds a named integer argument which can be given multiple times
+// to collect a list of integers
+func (c *Command) AddIntListArg(name, shortName, desc string, required bool) *IntListArgument {
+	ila := &IntListArgument{
+		argumentBase: argumentBase{
+			name:      name,
+			shortName: shortName,
+			desc:      desc,
+			required:  required,
+		},
+	}
+
+	c.addArg(ila)
+	return ila
+}
+
+// addArg adds an argument to a command
+func (c *Command) addArg(arg Argument) {
+	if err := c.checkArg(arg.Name(), arg.ShortName()); err != nil {
+		log.Fatalf("%s", err.Error())
 	}
 
 	c.args[arg.Name()] = arg
 	c.argsByShortName[arg.ShortName()] = arg
+	arg.base().command = c
+}
+
+// checkFlag checks whether a flag with the given names can be added to the
+// command without colliding with an existing flag
+func (c *Command) checkFlag(name, shortName string) error {
+	if err := c.checkNames("flag", name, shortName); err != nil {
+		return err
+	}
+
+	if _, ok := c.flags[name]; ok {
+		return fmt.Errorf("multiple flags named `%s`", name)
+	}
+
+	if _, ok := c.flagsByShortName[shortName]; ok {
+		return fmt.Errorf("multiple flags with short name `%s`", shortName)
+	}
+
+	for _, flag := range c.flags {
+		if err := c.checkCaseCollision("flag", name, shortName, flag.name, flag.shortName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkArg checks whether an argument with the given names can be added to the
+// command without colliding with an existing argument
+func (c *Command) checkArg(name, shortName string) error {
+	if err := c.checkNames("argument", name, shortName); err != nil {
+		return err
+	}
+
+	if _, ok := c.args[name]; ok {
+		return fmt.Errorf("multiple arguments named `%s`", name)
+	}
+
+	if _, ok := c.argsByShortName[shortName]; ok {
+		return fmt.Errorf("multiple arguments with short name `%s`", shortName)
+	}
+
+	for _, arg := range c.args {
+		if err := c.checkCaseCollision("argument", name, shortName, arg.Name(), arg.ShortName()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkCaseCollision checks that the names of a new flag or argument do not
+// differ only by case from those of an existing one if the CLI matches them
+// ignoring case.  Single character short names are exempt since they are always
+// matched exactly first.
+func (c *Command) checkCaseCollision(kind, name, shortName, otherName, otherShortName string) error {
+	root := c.root()
+	if root.CaseInsensitiveNames && strings.EqualFold(name, otherName) {
+		return fmt.Errorf("%s name `%s` collides with `%s` ignoring case", kind, name, otherName)
+	}
+
+	if root.CaseInsensitiveShortNames && utf8.RuneCountInString(shortName) > 1 && strings.EqualFold(shortName, otherShortName) {
+		return fmt.Errorf("%s short name `%s` collides with `%s` ignoring case", kind, shortName, otherShortName)
+	}
+
+	return nil
+}
+
+// checkNames checks that the name and short name of a flag or argument can be
+// parsed: they cannot begin with a dash or contain whitespace or an assignment
+// separator
+func (c *Command) checkNames(kind, name, shortName string) error {
+	for _, n := range []string{name, shortName} {
+		if strings.HasPrefix(n, "-") {
+			return fmt.Errorf("%s name `%s` cannot begin with a dash", kind, n)
+		}
+
+		if strings.IndexFunc(n, unicode.IsSpace) > -1 {
+			return fmt.Errorf("%s name `%s` cannot contain whitespace", kind, n)
+		}
+
+		if sep := c.root().assignSep; strings.Contains(n, "=") || strings.Contains(n, sep) {
+			return fmt.Errorf("%s name `%s` cannot contain an assignment separator", kind, n)
+		}
+	}
+
+	return nil
 }
 
 // EnableHelp enables the help flag (`--help` or `-h`).
 func (c *Command) EnableHelp() {
-	if _, ok := c.args["help"]; !ok {
-		flag := c.AddFlag("help", "h", "Get help")
-		flag.action = func() {
-			c.Help()
-			os.Exit(0)
+	if _, ok := c.flags["help"]; !ok {
+		c.addHelpFlag()
+	}
+}
+
+// SetVersion sets the version of the application and enables the version flag
+// (`--version` or `-V`) which displays the version and exits the application
+func (c *Command) SetVersion(v string) {
+	c.version = v
+
+	if _, ok := c.flags["version"]; !ok {
+		c.addVersionFlag()
+	}
+}
+
+// AddVersionCommand adds a `version` subcommand which displays the version set
+// by `SetVersion` when it is run by `Run` or `Execute`
+func (c *Command) AddVersionCommand() *Command {
+	vc := c.AddSubcommand("version", "Display the version", false)
+	vc.SetHandler(func(context.Context, *ArgParseResult) error {
+		fmt.Println(c.version)
+		return nil
+	})
+
+	return vc
+}
+
+// SetAvailableIf sets a function which determines whether or not this command
+// is available as a subcommand.  It is passed the result of the parent command
+// accumulated so far when the subcommand is encountered: the results of the
+// commands above the parent are reached through `Parent` (eg. to check a flag of
+// the initial command from a nested subcommand).  An unavailable subcommand is
+// treated as unknown and is not displayed in help.
+func (c *Command) SetAvailableIf(fn func(*ArgParseResult) bool) {
+	c.availableIf = fn
+}
+
+// SetDisplayName sets the name that is displayed for the command in its help
+// and usage messages.  This does not affect parsing and takes precedence over
+// the invoked name.
+func (c *Command) SetDisplayName(name string) {
+	c.displayName = name
+}
+
+// SetHelpHeader sets text (eg. a banner) displayed at the start of the
+// command's help message before its description
+func (c *Command) SetHelpHeader(header string) {
+	c.helpHeader = header
+}
+
+// SetHelpFooter sets text (eg. a link to documentation) displayed at the end of
+// the command's help message after all of its sections
+func (c *Command) SetHelpFooter(footer string) {
+	c.helpFooter = footer
+}
+
+// SetAssignmentSeparator sets the separator used between the name of an
+// argument and its value (eg. `:` for `--key:value`).  The separator must be a
+// single character that is neither a dash nor whitespace.  It applies to the
+// whole CLI and so can only be set on the initial command.  It cannot appear in
+// the names of any flags or arguments which have already been added.
+func (c *Command) SetAssignmentSeparator(sep string) {
+	if c.parent != nil {
+		log.Fatalf("the assignment separator can only be set on the initial command, not `%s`\n", c.Name)
+	}
+
+	if utf8.RuneCountInString(sep) != 1 || sep == "-" || strings.TrimSpace(sep) == "" {
+		log.Fatalf("invalid assignment separator: `%s`\n", sep)
+	} else if cmd, name, ok := c.findNameContaining(sep); ok {
+		log.Fatalf("invalid assignment separator: `%s` is used in the name `%s` of command `%s`\n", sep, name, cmd.Name)
+	}
+
+	c.assignSep = sep
+}
+
+// findNameContaining finds a name or short name of a flag or argument of the
+// command or any of its subcommands which contains a string.  It returns the
+// command the name belongs to and the name.
+func (c *Command) findNameContaining(s string) (*Command, string, bool) {
+	var names []string
+	for _, flag := range c.flags {
+		names = append(names, flag.name, flag.shortName)
+	}
+
+	for _, arg := range c.args {
+		names = append(names, arg.Name(), arg.ShortName())
+	}
+
+	for _, name := range names {
+		if strings.Contains(name, s) {
+			return c, name, true
+		}
+	}
+
+	for _, subc := range c.subcommands {
+		if cmd, name, ok := subc.findNameContaining(s); ok {
+			return cmd, name, true
 		}
 	}
+
+	return nil, "", false
+}
+
+// AssignmentSeparator returns the separator used between the name of an
+// argument and its value by the CLI the command belongs to
+func (c *Command) AssignmentSeparator() string {
+	return c.root().assignSep
 }
 
 // DisableHelp disables the help flag (`--help` or `-h`).
@@ -222,30 +810,256 @@ func (c *Command) DisableHelp() {
 
 // -----------------------------------------------------------------------------
 
+// Subcommands returns the subcommands of the command sorted by name
+func (c *Command) Subcommands() []*Command {
+	subcs := make([]*Command, 0, len(c.subcommands))
+	for _, subc := range c.subcommands {
+		subcs = append(subcs, subc)
+	}
+
+	sort.Slice(subcs, func(i, j int) bool {
+		return subcs[i].Name < subcs[j].Name
+	})
+
+	return subcs
+}
+
+// Flags returns the flags of the command sorted by name
+func (c *Command) Flags() []*Flag {
+	flags := make([]*Flag, 0, len(c.flags))
+	for _, flag := range c.flags {
+		flags = append(flags, flag)
+	}
+
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].name < flags[j].name
+	})
+
+	return flags
+}
+
+// Arguments returns the named arguments of the command sorted by name
+func (c *Command) Arguments() []Argument {
+	args := make([]Argument, 0, len(c.args))
+	for _, arg := range c.args {
+		args = append(args, arg)
+	}
+
+	sort.Slice(args, func(i, j int) bool {
+		return args[i].Name() < args[j].Name()
+	})
+
+	return args
+}
+
+// HasFlagDefined checks if a flag with the given name is defined on the
+// command.  This does not check the command's parents.
+func (c *Command) HasFlagDefined(name string) bool {
+	_, ok := c.flags[name]
+	return ok
+}
+
+// HasArgDefined checks if a named argument with the given name is defined on
+// the command.  This does not check the command's parents.
+func (c *Command) HasArgDefined(name string) bool {
+	_, ok := c.args[name]
+	return ok
+}
+
+// PrimaryArgument returns the primary argument of the command if it has one
+func (c *Command) PrimaryArgument() (*PrimaryArgument, bool) {
+	return c.primaryArg, c.primaryArg != nil
+}
+
+// -----------------------------------------------------------------------------
+
 // HasFlag checks if a flag has been set during argument parsing
 func (apr *ArgParseResult) HasFlag(name string) bool {
 	_, ok := apr.flags[name]
 	return ok
 }
 
-// PrimaryArg gets the primary argument if one exists
+// FlagCount returns the number of times a flag was given.  For countable flags
+// this includes any count given explicitly.  Other flags count at most once.
+func (apr *ArgParseResult) FlagCount(name string) int {
+	if count, ok := apr.counts[name]; ok {
+		return count
+	}
+
+	if apr.HasFlag(name) {
+		return 1
+	}
+
+	return 0
+}
+
+// FlagNegated checks if a negatable flag was negated during argument parsing
+// (eg. by `--no-verbose`)
+func (apr *ArgParseResult) FlagNegated(name string) bool {
+	_, ok := apr.negated[name]
+	return ok
+}
+
+// HasFlagRecursive checks if a flag was set on the result of any command in the
+// chain of commands this result belongs to: its parents as well as the selected
+// subcommands.  This is useful for global flags defined on a parent command.
+func (apr *ArgParseResult) HasFlagRecursive(name string) bool {
+	res := apr
+	for res.parent != nil {
+		res = res.parent
+	}
+
+	for ; res != nil; res = res.subcommandRes {
+		if res.HasFlag(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PrimaryArg gets the primary argument if one exists.  For a variadic primary
+// argument, this is its first value.
 func (apr *ArgParseResult) PrimaryArg() (string, bool) {
 	return apr.primaryArg, apr.primaryArg != ""
 }
 
+// PrimaryArgs gets all the values of the primary argument in the order they
+// were given.  There is at most one value unless the primary argument is
+// variadic.
+func (apr *ArgParseResult) PrimaryArgs() []string {
+	if len(apr.primaryArgs) > 0 {
+		return apr.primaryArgs
+	} else if apr.primaryArg != "" {
+		return []string{apr.primaryArg}
+	}
+
+	return nil
+}
+
+// Command returns the command this is the result of
+func (apr *ArgParseResult) Command() *Command {
+	return apr.command
+}
+
+// Parent returns the result of the parent command if this is the result of a
+// subcommand and `nil` otherwise
+func (apr *ArgParseResult) Parent() *ArgParseResult {
+	return apr.parent
+}
+
+// TrailingArgs gets the arguments that were collected verbatim after the
+// primary argument of a command which collects trailing arguments or after the
+// `--` terminator
+func (apr *ArgParseResult) TrailingArgs() []string {
+	return apr.trailingArgs
+}
+
+// Halted indicates whether or not an action which would normally exit the
+// application (eg. displaying help) stopped `LenientParse` early.  A halted
+// result is partial: the arguments after the action were not parsed and the
+// checks for required arguments and groups were skipped.  This is only set on
+// the result of the initial command.
+func (apr *ArgParseResult) Halted() bool {
+	return apr.halted
+}
+
+// MissingRequired returns the qualified names of all the required arguments
+// that did not receive a value.  Arguments of subcommands are qualified by the
+// path of subcommands leading to them (eg. `build.output`).  This is only
+// populated on the result of the initial command.  Since `ParseArgs` and
+// `LenientParse` fail if any required arguments are missing, this is empty on
+// any result they return: the names are attached to the `MissingRequiredError`
+// returned instead (use `errors.As` to access them).
+func (apr *ArgParseResult) MissingRequired() []string {
+	return apr.missingRequired
+}
+
+// WasProvided indicates whether or not the user provided the value of the
+// argument with the given name (as opposed to it being a default or base value)
+func (apr *ArgParseResult) WasProvided(name string) bool {
+	_, ok := apr.provided[name]
+	return ok
+}
+
+// Effective returns the values of the arguments of this result and the results
+// of its subcommands after all defaults have been applied.  The names of the
+// arguments of subcommands are qualified by the path of subcommands leading to
+// them (eg. `build.output`).  This should be called on the result of the initial
+// command.
+func (apr *ArgParseResult) Effective() map[string]interface{} {
+	values := make(map[string]interface{})
+
+	prefix := ""
+	for res := apr; res != nil; res = res.subcommandRes {
+		for name, val := range res.Arguments {
+			values[prefix+name] = val
+		}
+
+		prefix += res.subcommandName + "."
+	}
+
+	return values
+}
+
+// Validate runs the deferred validation of an argument with lazy validation.
+// It returns `nil` if the argument has no value in this result or does not use
+// lazy validation.
+func (apr *ArgParseResult) Validate(name string) error {
+	if lv, ok := apr.lazyValues[name]; ok {
+		return lv.arg.validate(lv.raw, lv.value)
+	}
+
+	return nil
+}
+
 // Subcommand gets the subcommand if one exists
 func (apr *ArgParseResult) Subcommand() (string, *ArgParseResult, bool) {
 	return apr.subcommandName, apr.subcommandRes, apr.subcommandRes != nil
 }
 
+// GetStringSlice gets the value of a string list argument.  It returns an empty
+// slice and false if the argument has no value or is not a string list.
+func (apr *ArgParseResult) GetStringSlice(name string) ([]string, bool) {
+	if elems, ok := Get[[]string](apr, name); ok {
+		return elems, true
+	}
+
+	return []string{}, false
+}
+
+// GetIntSlice gets the value of an integer list argument.  It returns an empty
+// slice and false if the argument has no value or is not an integer list.
+func (apr *ArgParseResult) GetIntSlice(name string) ([]int, bool) {
+	if elems, ok := Get[[]int](apr, name); ok {
+		return elems, true
+	}
+
+	return []int{}, false
+}
+
+// Get gets the value of an argument of a result as a `T` (eg.
+// `olive.Get[int](result, "port")`).  It returns the zero value and false if
+// the argument has no value or its value is not a `T`.
+func Get[T any](apr *ArgParseResult, name string) (T, bool) {
+	val, ok := apr.Arguments[name].(T)
+	return val, ok
+}
+
 // -----------------------------------------------------------------------------
 
 // Help displays the help message for a given command
 func (c *Command) Help() {
-	fmt.Println(getHelpMessage(c))
+	fmt.Println(getHelpMessage(c, nil))
+}
+
+// WriteHelpTo writes the help message for a given command to a writer as it is
+// generated rather than building the whole message first
+func (c *Command) WriteHelpTo(w io.Writer) error {
+	return writeHelpMessage(w, c, nil)
 }
 
 // HelpMessage returns the stringified help message for a given command
 func (c *Command) HelpMessage() string {
-	return getHelpMessage(c)
+	return getHelpMessage(c, nil)
 }
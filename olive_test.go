@@ -1,12 +1,18 @@
 package olive_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/ComedicChimera/olive"
@@ -421,6 +427,11 @@ func TestBadInput(t *testing.T) {
 	}
 
 	_, err = olive.ParseArgs(cli, []string{"olive", "-f", "mod"})
+	if err == nil {
+		t.Fatal("missing unknown flag error")
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "version", "mod"})
 	if err == nil {
 		t.Fatal("missing unexpected subcommand error")
 	}
@@ -438,7 +449,12 @@ func TestBadInput2(t *testing.T) {
 		t.Fatal("missing multiple primary arguments error")
 	}
 
-	result, err := olive.ParseArgs(cli, []string{"olive", "-f1", "prim"})
+	_, err = olive.ParseArgs(cli, []string{"olive", "-f1", "prim"})
+	if err == nil || err.Error() != "missing required argument: `sel`" {
+		t.Fatalf("expected a missing required argument error, got %v", err)
+	}
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "-f1", "-s=val1", "prim"})
 	if err != nil {
 		t.Fatalf("unexpected error: %s", err.Error())
 	}
@@ -763,3 +779,3914 @@ func TestBadDefaultValues(t *testing.T) {
 		t.Fatalf("expected `4` fatal errors; received `%d`", logFatalCount)
 	}
 }
+
+func TestPromptForMissing(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+
+	cli.AddIntArg("int", "i", "", true)
+	cli.AddStringArg("str", "s", "", false)
+
+	cli.PromptForMissing = true
+	cli.Prompter = olive.NewReaderPrompter(strings.NewReader("12\n"), io.Discard)
+
+	result, err := olive.ParseArgs(cli, []string{"olive"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if result.Arguments["int"].(int) != 12 {
+		t.Fatalf("expected prompted value of `12` for argument `int`, not `%v`", result.Arguments["int"])
+	}
+
+	if _, ok := result.Arguments["str"]; ok {
+		t.Fatal("optional argument `str` should not be prompted for")
+	}
+
+	cli.Prompter = olive.NewReaderPrompter(strings.NewReader("abc\n"), io.Discard)
+	_, err = olive.ParseArgs(cli, []string{"olive"})
+	if err == nil {
+		t.Fatal("missing invalid prompted value error")
+	}
+
+	cli.Prompter = olive.NewReaderPrompter(strings.NewReader(""), io.Discard)
+	result, err = olive.ParseArgs(cli, []string{"olive", "-i=4"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if result.Arguments["int"].(int) != 4 {
+		t.Fatalf("expected value of `4` for argument `int`, not `%v`", result.Arguments["int"])
+	}
+}
+
+func TestStringListArgs(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+
+	sla := cli.AddStringListArg("paths", "p", "", false)
+	sla.SetValidator(func(x string) error {
+		if x == "bad" {
+			return errors.New("bad path")
+		}
+
+		return nil
+	})
+
+	cases := map[string][]string{
+		"a,b,c":      {"a", "b", "c"},
+		`a\,b,c`:     {"a,b", "c"},
+		"a,b,":       {"a", "b"},
+		"a,,b":       {"a", "", "b"},
+		`a\\,b`:      {`a\`, "b"},
+		`a\\\,b`:     {`a\,b`},
+		`a\b`:        {`a\b`},
+		`a\\,`:       {`a\`},
+		`trailing\,`: {"trailing,"},
+	}
+
+	for input, expected := range cases {
+		result, err := olive.ParseArgs(cli, []string{"olive", "--paths=" + input})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		if !reflect.DeepEqual(result.Arguments["paths"], expected) {
+			t.Fatalf("expected `%q` for input `%s`, not `%q`", expected, input, result.Arguments["paths"])
+		}
+	}
+
+	_, err := olive.ParseArgs(cli, []string{"olive", "-p=a,bad"})
+	if err == nil {
+		t.Fatal("missing list element validator error")
+	}
+}
+
+func TestSubcommandHelp(t *testing.T) {
+	exitCount := 0
+	monkey.Patch(os.Exit, func(int) {
+		exitCount++
+	})
+
+	defer monkey.Unpatch(os.Exit)
+
+	var helpOutput string
+	monkey.Patch(fmt.Println, func(a ...interface{}) (int, error) {
+		helpOutput = fmt.Sprint(a...)
+		return 0, nil
+	})
+
+	defer monkey.Unpatch(fmt.Println)
+
+	cli := olive.NewCLI("olive", "Root description", true)
+	cli.AddSubcommand("sub", "Sub description", false)
+
+	mod := cli.AddSubcommand("mod", "Mod description", true)
+	mod.AddSubcommand("init", "Init description", false)
+
+	_, err := olive.ParseArgs(cli, []string{"olive", "sub", "--help"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !strings.HasPrefix(helpOutput, "Sub description") {
+		t.Fatalf("expected help for `sub`, got:\n%s", helpOutput)
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "mod", "init", "-h"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !strings.HasPrefix(helpOutput, "Init description") {
+		t.Fatalf("expected help for `init`, got:\n%s", helpOutput)
+	}
+
+	cli.EnableHelp()
+	cli.RequiresSubcommand = false
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "-h"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !strings.HasPrefix(helpOutput, "Root description") {
+		t.Fatalf("expected help for `olive`, got:\n%s", helpOutput)
+	}
+
+	if exitCount != 3 {
+		t.Fatalf("expected help to exit `3` times, not `%d`", exitCount)
+	}
+}
+
+func TestAddDefs(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+
+	err := cli.AddFlags([]olive.FlagDef{
+		{Name: "verbose", ShortName: "v", Description: "Verbose output"},
+		{Name: "quiet", ShortName: "q"},
+		{Name: "verbose", ShortName: "vb"},
+		{Name: "query", ShortName: "q"},
+	})
+
+	if errs, ok := err.(olive.ConfigErrors); !ok || len(errs) != 2 {
+		t.Fatalf("expected `2` config errors, not `%v`", err)
+	}
+
+	err = cli.AddArgs([]olive.ArgDef{
+		{Name: "int", ShortName: "i", Type: olive.IntArgType, Default: 5},
+		{Name: "sel", ShortName: "s", Type: olive.SelectorArgType, PossibleValues: []string{"a", "b"}, Default: "b"},
+		{Name: "paths", ShortName: "p", Type: olive.StringListArgType},
+		{Name: "float", ShortName: "f", Type: olive.FloatArgType, Default: "bad"},
+		{Name: "sel2", ShortName: "s2", Type: olive.SelectorArgType, PossibleValues: []string{"a"}, Default: "c"},
+		{Name: "int", ShortName: "i2", Type: olive.IntArgType},
+	})
+
+	if errs, ok := err.(olive.ConfigErrors); !ok || len(errs) != 3 {
+		t.Fatalf("expected `3` config errors, not `%v`", err)
+	}
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "-v", "-q", "-p=a,b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !result.HasFlag("verbose") || !result.HasFlag("quiet") {
+		t.Fatal("missing flags `verbose` and `quiet`")
+	}
+
+	if !reflect.DeepEqual(result.Arguments, map[string]interface{}{
+		"int":   5,
+		"sel":   "b",
+		"paths": []string{"a", "b"},
+	}) {
+		t.Fatalf("bad argument values: %v", result.Arguments)
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-f=1.0"}); err == nil {
+		t.Fatal("invalid argument `float` should not have been added")
+	}
+}
+
+func TestWarningWriter(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %s", err.Error())
+	}
+
+	defer r.Close()
+	defer w.Close()
+
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	cli := olive.NewCLI("olive", "", true)
+	cli.RequiresSubcommand = false
+	cli.PromptForMissing = true
+
+	c := cli.AddSubcommand("sub", "", true)
+	c.AddIntArg("int", "i", "", true)
+
+	buff := &strings.Builder{}
+	cli.WarningWriter = buff
+
+	// the argument is still missing since it cannot be prompted for
+	if _, err := olive.ParseArgs(cli, []string{"olive", "sub"}); err == nil || err.Error() != "missing required argument: `sub.int`" {
+		t.Fatalf("expected a missing required argument error, got %v", err)
+	}
+
+	if !strings.Contains(buff.String(), "not a terminal") {
+		t.Fatalf("expected a warning about prompting, not `%s`", buff.String())
+	}
+
+	buff.Reset()
+	if _, err := olive.ParseArgs(cli, []string{"olive"}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if buff.Len() != 0 {
+		t.Fatalf("unexpected warning: `%s`", buff.String())
+	}
+
+	cli.WarningWriter = io.Discard
+	if _, err := olive.ParseArgs(cli, []string{"olive", "sub"}); err == nil {
+		t.Fatal("expected a missing required argument error")
+	}
+
+	if buff.Len() != 0 {
+		t.Fatalf("warnings should be discarded: `%s`", buff.String())
+	}
+}
+
+func TestDisabledOptions(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+
+	f := cli.AddFlag("experimental", "x", "Enable experimental features")
+	f.SetEnabled(false)
+
+	a := cli.AddIntArg("jobs", "j", "Number of jobs", true)
+	a.SetDefaultValue(4)
+	a.SetEnabled(false)
+
+	if f.Enabled() || a.Enabled() {
+		t.Fatal("options should be disabled")
+	}
+
+	result, err := olive.ParseArgs(cli, []string{"olive"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if _, ok := result.Arguments["jobs"]; ok {
+		t.Fatal("disabled argument `jobs` should not receive its default value")
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-x"}); err == nil {
+		t.Fatal("missing unknown flag error for disabled flag")
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "--jobs=2"}); err == nil {
+		t.Fatal("missing unknown argument error for disabled argument")
+	}
+
+	help := cli.HelpMessage()
+	if strings.Contains(help, "experimental") || strings.Contains(help, "jobs") || strings.Contains(help, "Arguments:") {
+		t.Fatalf("disabled options should not be displayed in help:\n%s", help)
+	}
+
+	f.SetEnabled(true)
+	a.SetEnabled(true)
+
+	result, err = olive.ParseArgs(cli, []string{"olive", "-x", "-j=2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !result.HasFlag("experimental") || result.Arguments["jobs"].(int) != 2 {
+		t.Fatal("missing enabled options")
+	}
+
+	help = cli.HelpMessage()
+	if !strings.Contains(help, "experimental") || !strings.Contains(help, "jobs") {
+		t.Fatalf("enabled options should be displayed in help:\n%s", help)
+	}
+}
+
+func TestUseInvokedName(t *testing.T) {
+	var help string
+	monkey.Patch(fmt.Println, func(a ...interface{}) (int, error) {
+		help = fmt.Sprint(a...)
+		return 0, nil
+	})
+
+	defer monkey.Unpatch(fmt.Println)
+
+	cli := olive.NewCLI("olive", "", true)
+
+	if _, err := olive.LenientParse(cli, []string{"/usr/bin/olive-link", "--help"}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if strings.Contains(help, "olive-link") {
+		t.Fatal("invoked name should not be used unless enabled")
+	}
+
+	cli.UseInvokedName = true
+
+	if _, err := olive.LenientParse(cli, []string{"/usr/bin/olive-link", "--help"}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !strings.Contains(help, "    olive-link [-h|--help]") {
+		t.Fatalf("expected invoked name in usage line:\n%s", help)
+	}
+
+	// parsing does not change the definition of the CLI
+	if strings.Contains(cli.HelpMessage(), "olive-link") {
+		t.Fatalf("invoked name should not be used outside of parsing:\n%s", cli.HelpMessage())
+	}
+}
+
+func TestValidatorErrors(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+
+	errOdd := errors.New("must be even")
+	ia := cli.AddIntArg("int", "i", "", false)
+	ia.SetValidator(func(x int) error {
+		if x%2 == 1 {
+			return errOdd
+		}
+
+		return nil
+	})
+
+	errNone := errors.New("must not be none")
+	sea := cli.AddSelectorArg("sel", "s", "", false, []string{"some", "none"})
+	sea.SetValidator(func(x string) error {
+		if x == "none" {
+			return errNone
+		}
+
+		return nil
+	})
+
+	_, err := olive.ParseArgs(cli, []string{"olive", "--int=5"})
+	if err == nil || err.Error() != `argument 1: argument "int" rejected value "5": must be even` {
+		t.Fatalf("unexpected validator error: %v", err)
+	}
+
+	if !errors.Is(err, errOdd) {
+		t.Fatal("validator error should wrap the original error")
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "-s=none"})
+	if err == nil || err.Error() != `argument 1: argument "sel" rejected value "none": must not be none` {
+		t.Fatalf("unexpected validator error: %v", err)
+	}
+
+	if !errors.Is(err, errNone) {
+		t.Fatal("validator error should wrap the original error")
+	}
+}
+
+func TestCollectTrailingArgs(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+
+	c := cli.AddSubcommand("run", "", true)
+	c.AddPrimaryArg("script", "", true)
+	c.AddFlag("watch", "w", "")
+	c.CollectTrailingArgs = true
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "run", "-v", "-w", "script.sh", "arg1", "--verbose", "-w", "sub"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	_, res, _ := result.Subcommand()
+	if prim, _ := res.PrimaryArg(); prim != "script.sh" {
+		t.Fatalf("expected primary argument `script.sh`, not `%s`", prim)
+	}
+
+	if !res.HasFlag("watch") || !result.HasFlag("verbose") {
+		t.Fatal("missing flags before the primary argument")
+	}
+
+	if !reflect.DeepEqual(res.TrailingArgs(), []string{"arg1", "--verbose", "-w", "sub"}) {
+		t.Fatalf("unexpected trailing arguments: %v", res.TrailingArgs())
+	}
+
+	result, err = olive.ParseArgs(cli, []string{"olive", "run", "script.sh"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	_, res, _ = result.Subcommand()
+	if len(res.TrailingArgs()) != 0 {
+		t.Fatalf("unexpected trailing arguments: %v", res.TrailingArgs())
+	}
+}
+
+func TestHelpJSON(t *testing.T) {
+	cli := olive.NewCLI("olive", "Olive CLI", true)
+	cli.AddFlag("verbose", "v", "Verbose output")
+
+	c := cli.AddSubcommand("build", "Build a package", false)
+	c.AddPrimaryArg("package", "The package", true)
+	c.AddIntArg("jobs", "j", "Number of jobs", false).SetDefaultValue(4)
+	c.AddSelectorArg("mode", "m", "Build mode", true, []string{"debug", "release"})
+
+	data, err := cli.HelpJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	var tree map[string]interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		t.Fatalf("invalid JSON: %s", err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"name":               "olive",
+		"description":        "Olive CLI",
+		"requiresSubcommand": true,
+		"arguments":          []interface{}{},
+		"flags": []interface{}{
+			map[string]interface{}{"name": "help", "shortName": "h", "description": "Get help"},
+			map[string]interface{}{"name": "verbose", "shortName": "v", "description": "Verbose output"},
+		},
+		"subcommands": []interface{}{
+			map[string]interface{}{
+				"name":               "build",
+				"description":        "Build a package",
+				"requiresSubcommand": false,
+				"primaryArgument":    map[string]interface{}{"name": "package", "description": "The package", "required": true},
+				"arguments": []interface{}{
+					map[string]interface{}{"name": "jobs", "shortName": "j", "description": "Number of jobs", "type": "int", "required": false, "default": 4.0},
+					map[string]interface{}{"name": "mode", "shortName": "m", "description": "Build mode", "type": "selector", "required": true, "possibleValues": []interface{}{"debug", "release"}},
+				},
+				"flags":       []interface{}{},
+				"subcommands": []interface{}{},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(tree, expected) {
+		t.Fatalf("unexpected help JSON: %s", string(data))
+	}
+}
+
+func TestSuggestNames(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	cli.AddIntArg("verbosity", "vb", "", false)
+	cli.AddSubcommand("build", "", true).AddStringArg("output", "o", "", false)
+	cli.RequiresSubcommand = false
+
+	_, err := olive.ParseArgs(cli, []string{"olive", "--verbos"})
+	if err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Fatalf("suggestions should be disabled by default: %v", err)
+	}
+
+	cli.SuggestNames = true
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "--verbos"})
+	if err == nil || err.Error() != "argument 1: unknown flag: `verbos`, did you mean `--verbose`?" {
+		t.Fatalf("unexpected flag suggestion: %v", err)
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "--verbosty=1"})
+	if err == nil || err.Error() != "argument 1: unknown argument: `verbosty`, did you mean `--verbosity`?" {
+		t.Fatalf("unexpected argument suggestion: %v", err)
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "build", "--outptu=dir"})
+	if err == nil || err.Error() != "argument 2: unknown argument: `outptu`, did you mean `--output`?" {
+		t.Fatalf("unexpected argument suggestion: %v", err)
+	}
+
+	// an argument given without a value may be a misspelled argument whose
+	// value is the next token
+	_, err = olive.ParseArgs(cli, []string{"olive", "build", "--outpt", "dir"})
+	if err == nil || err.Error() != "argument 2: unknown argument: `outpt`, did you mean `--output`?" {
+		t.Fatalf("unexpected argument suggestion: %v", err)
+	}
+
+	var uae *olive.UnknownArgError
+	if !errors.As(err, &uae) || uae.Suggestion != "output" {
+		t.Fatalf("expected an unknown argument error, got %v", err)
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "--output=dir"})
+	if err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Fatalf("arguments of unentered subcommands should not be suggested: %v", err)
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "--completely-different"})
+	if err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Fatalf("unexpected suggestion: %v", err)
+	}
+}
+
+func TestAssignmentSeparator(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	c := cli.AddSubcommand("build", "", true)
+	c.AddStringArg("output", "o", "", false)
+
+	cli.SetAssignmentSeparator(":")
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "build", "--output:a:b=c"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	_, res, _ := result.Subcommand()
+	if res.Arguments["output"].(string) != "a:b=c" {
+		t.Fatalf("expected value of `a:b=c` for argument `output`, not `%s`", res.Arguments["output"].(string))
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "build", "-o=path"}); err == nil {
+		t.Fatal("`=` should no longer separate argument values")
+	}
+
+	if !strings.Contains(c.HelpMessage(), "[-o|--output:<string>]") {
+		t.Fatalf("usage line should use the assignment separator:\n%s", c.HelpMessage())
+	}
+
+	if c.AssignmentSeparator() != ":" {
+		t.Fatalf("expected the subcommand to report the separator of the CLI, got `%s`", c.AssignmentSeparator())
+	}
+
+	logFatalCount := 0
+	monkey.Patch(log.Fatalf, func(format string, v ...interface{}) {
+		logFatalCount++
+	})
+
+	defer monkey.Unpatch(log.Fatalf)
+
+	cli.SetAssignmentSeparator("-")
+	cli.SetAssignmentSeparator("::")
+	cli.SetAssignmentSeparator(" ")
+	cli.SetAssignmentSeparator("")
+	c.SetAssignmentSeparator("@")
+
+	if logFatalCount != 5 {
+		t.Fatalf("expected `5` fatal errors; received `%d`", logFatalCount)
+	}
+
+	// names which are already registered cannot contain the new separator
+	cli = olive.NewCLI("olive", "", true)
+	cli.AddSubcommand("build", "", true).AddStringArg("key@value", "kv", "", false)
+	cli.SetAssignmentSeparator("@")
+
+	if logFatalCount != 6 {
+		t.Fatalf("expected `6` fatal errors; received `%d`", logFatalCount)
+	}
+}
+
+func TestMissingRequired(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddIntArg("jobs", "j", "", true)
+	cli.AddStringArg("config", "c", "", true)
+
+	c := cli.AddSubcommand("build", "", true)
+	c.AddStringArg("output", "o", "", true)
+	c.AddStringArg("profile", "p", "", true).SetDefaultValue("dev")
+
+	cli.AddSubcommand("mod", "", true).AddStringArg("name", "n", "", true)
+
+	_, err := olive.ParseArgs(cli, []string{"olive", "build", "-c=file"})
+	if err == nil || err.Error() != "missing required argument: `jobs`" {
+		t.Fatalf("expected a missing required argument error, got %v", err)
+	}
+
+	var mre *olive.MissingRequiredError
+	if !errors.As(err, &mre) || !reflect.DeepEqual(mre.Missing, []string{"jobs", "build.output"}) {
+		t.Fatalf("expected all the missing arguments on the error, got %v", mre)
+	}
+
+	// lenient parsing never exits but still enforces required arguments
+	_, err = olive.LenientParse(cli, []string{"olive", "build", "-c=file"})
+	if !errors.As(err, &mre) || !reflect.DeepEqual(mre.Missing, []string{"jobs", "build.output"}) {
+		t.Fatalf("expected a missing required argument error from a lenient parse, got %v", err)
+	}
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "build", "-c=file", "-j=2", "-o=out"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if len(result.MissingRequired()) != 0 {
+		t.Fatalf("unexpected missing required arguments: %v", result.MissingRequired())
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	cli := olive.NewCLI("git-foo", "", true)
+	cli.AddSubcommand("bar", "", true)
+	cli.UseInvokedName = true
+	cli.SetDisplayName("git foo")
+
+	if !strings.Contains(cli.HelpMessage(), "    git foo <command>") {
+		t.Fatalf("expected display name in usage line:\n%s", cli.HelpMessage())
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"/bin/git-foo", "bar"}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !strings.Contains(cli.HelpMessage(), "    git foo <command>") {
+		t.Fatalf("display name should take precedence over the invoked name:\n%s", cli.HelpMessage())
+	}
+}
+
+func TestNumberErrors(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddIntArg("int", "i", "", false)
+	cli.AddFloatArg("float", "f", "", false)
+
+	cases := map[string]string{
+		"--int=99999999999999999999":  `argument "int" value "99999999999999999999" is too large`,
+		"--int=-99999999999999999999": `argument "int" value "-99999999999999999999" is too small`,
+		"--int=abc":                   `argument "int" value "abc" must be an integer`,
+		"--int=1.5":                   `argument "int" value "1.5" must be an integer`,
+		"--float=1e400":               `argument "float" value "1e400" is too large`,
+		"--float=-1e400":              `argument "float" value "-1e400" is too small`,
+		"--float=abc":                 `argument "float" value "abc" must be a number`,
+	}
+
+	for input, msg := range cases {
+		_, err := olive.ParseArgs(cli, []string{"olive", input})
+		if msg = "argument 1: " + msg; err == nil || err.Error() != msg {
+			t.Fatalf("expected error `%s` for `%s`, not `%v`", msg, input, err)
+		}
+	}
+}
+
+func TestAvailableIf(t *testing.T) {
+	experimental := false
+
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddSubcommand("build", "Build the project", true)
+
+	exp := cli.AddSubcommand("exp", "Experimental command", true)
+	exp.SetAvailableIf(func(res *olive.ArgParseResult) bool {
+		return experimental
+	})
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "exp"}); err == nil {
+		t.Fatal("missing unknown subcommand error for unavailable subcommand")
+	}
+
+	if strings.Contains(cli.HelpMessage(), "Experimental command") {
+		t.Fatalf("unavailable subcommand should not be displayed:\n%s", cli.HelpMessage())
+	}
+
+	experimental = true
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "exp"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if name, _, _ := result.Subcommand(); name != "exp" {
+		t.Fatalf("expected subcommand `exp`, not `%s`", name)
+	}
+
+	if !strings.Contains(cli.HelpMessage(), "Experimental command") {
+		t.Fatalf("available subcommand should be displayed:\n%s", cli.HelpMessage())
+	}
+}
+
+func TestAvailableIfNested(t *testing.T) {
+	var help string
+	monkey.Patch(fmt.Println, func(a ...interface{}) (int, error) {
+		help = fmt.Sprint(a...)
+		return 0, nil
+	})
+
+	defer monkey.Unpatch(fmt.Println)
+
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("experimental", "x", "")
+
+	mod := cli.AddSubcommand("mod", "", true)
+	mod.AddSubcommand("init", "Initialize a module", true)
+
+	// the subcommand depends on a flag of the initial command two levels up
+	graph := mod.AddSubcommand("graph", "Graph the dependencies", true)
+	graph.SetAvailableIf(func(res *olive.ArgParseResult) bool {
+		return res.Parent() != nil && res.Parent().HasFlag("experimental")
+	})
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "mod", "graph"}); err == nil {
+		t.Fatal("missing unknown subcommand error for unavailable subcommand")
+	}
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "-x", "mod", "graph"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if _, modRes, _ := result.Subcommand(); modRes.Parent() != result {
+		t.Fatal("expected the parent of the subcommand result to be the initial result")
+	}
+
+	if _, err := olive.LenientParse(cli, []string{"olive", "-x", "mod", "--help"}); err != nil || !strings.Contains(help, "Graph the dependencies") {
+		t.Fatalf("expected the available subcommand in help:\n%s", help)
+	}
+
+	if _, err := olive.LenientParse(cli, []string{"olive", "mod", "--help"}); err != nil || strings.Contains(help, "Graph the dependencies") {
+		t.Fatalf("expected the unavailable subcommand to be omitted from help:\n%s", help)
+	}
+}
+
+func TestDefinitionQueries(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	cli.AddIntArg("jobs", "j", "", false)
+
+	c := cli.AddSubcommand("build", "", false)
+
+	if !cli.HasFlagDefined("verbose") || !cli.HasFlagDefined("help") || cli.HasFlagDefined("jobs") {
+		t.Fatal("incorrect flag definitions")
+	}
+
+	if !cli.HasArgDefined("jobs") || cli.HasArgDefined("verbose") {
+		t.Fatal("incorrect argument definitions")
+	}
+
+	if c.HasFlagDefined("verbose") || c.HasFlagDefined("help") || c.HasArgDefined("jobs") {
+		t.Fatal("subcommand should not report its parent's definitions")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+
+	mod := cli.AddSubcommand("mod", "", true)
+	mod.AddSubcommand("init", "", true)
+
+	plugin := olive.NewCLI("plugin", "", true)
+	plugin.AddIntArg("jobs", "j", "", false)
+	plugin.AddSubcommand("build", "", true).AddPrimaryArg("package", "", true)
+	plugin.AddSubcommand("mod", "", true).AddSubcommand("update", "", true)
+
+	if err := cli.Merge(plugin); err != nil {
+		t.Fatalf("unexpected merge error: %s", err.Error())
+	}
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "build", "-v", "-j=2", "pkg"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !result.HasFlag("verbose") || result.Arguments["jobs"].(int) != 2 {
+		t.Fatal("missing merged options")
+	}
+
+	for _, sub := range []string{"init", "update"} {
+		if _, err := olive.ParseArgs(cli, []string{"olive", "mod", sub}); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	// the collision is nested so the top-level definitions would be merged if
+	// the merge was not checked first
+	conflicting := olive.NewCLI("conflict", "", false)
+	conflicting.AddFlag("other", "o", "")
+	conflicting.AddSubcommand("mod2", "", true)
+	conflicting.AddSubcommand("mod", "", true).AddSubcommand("init", "", true).AddFlag("force", "f", "")
+	conflicting.AddSubcommand("build", "", true).AddSubcommand("sub", "", true)
+
+	if err := cli.Merge(conflicting); err == nil {
+		t.Fatal("missing merge collision error")
+	}
+
+	if cli.HasFlagDefined("other") || len(cli.Subcommands()) != 2 {
+		t.Fatal("failed merge should not modify the command")
+	}
+}
+
+func TestMergeAvailability(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	cli.AddFlag("experimental", "x", "")
+	mod := cli.AddSubcommand("mod", "", true)
+	mod.AddSubcommand("init", "", true)
+	mod.SetAvailableIf(func(res *olive.ArgParseResult) bool {
+		return res.HasFlag("verbose")
+	})
+
+	plugin := olive.NewCLI("plugin", "", true)
+	pluginMod := plugin.AddSubcommand("mod", "", true)
+	pluginMod.AddSubcommand("update", "", true)
+	pluginMod.SetAvailableIf(func(res *olive.ArgParseResult) bool {
+		return res.HasFlag("experimental")
+	})
+
+	if err := cli.Merge(plugin); err != nil {
+		t.Fatalf("unexpected merge error: %s", err.Error())
+	}
+
+	for _, args := range [][]string{{"olive", "mod", "init"}, {"olive", "-v", "mod", "init"}, {"olive", "-x", "mod", "update"}} {
+		if _, err := olive.ParseArgs(cli, args); err == nil {
+			t.Fatalf("expected the merged subcommand to be unavailable for %v", args)
+		}
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-v", "-x", "mod", "update"}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestHelpAlignment(t *testing.T) {
+	cli := olive.NewCLI("olive", "", false)
+
+	desc := strings.Repeat("alpha ", 20)
+	cli.AddIntArg("int", "i", desc, false)
+	cli.AddSelectorArg("selector", "se", desc, false, []string{"a", "b"})
+	cli.AddStringArg("s", "str", desc, false)
+
+	help := cli.HelpMessage()
+	section := help[strings.Index(help, "Arguments:"):]
+
+	column := -1
+	lines := 0
+	for _, line := range strings.Split(section, "\n")[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		ndx := strings.Index(line, "alpha")
+		if column == -1 {
+			column = ndx
+		} else if ndx != column {
+			t.Fatalf("misaligned description column in help:\n%s", section)
+		}
+
+		lines++
+	}
+
+	if lines <= 3 {
+		t.Fatalf("expected descriptions to be wrapped:\n%s", section)
+	}
+}
+
+func TestRequiredPrimaryArg(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddSubcommand("build", "", true).AddPrimaryArg("package-name", "", true)
+	cli.AddSubcommand("run", "", true).AddPrimaryArg("script", "", false)
+
+	_, err := olive.ParseArgs(cli, []string{"olive", "build"})
+	if err == nil || err.Error() != `command "build" requires argument <package-name>` {
+		t.Fatalf("unexpected missing primary argument error: %v", err)
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "build", "pkg"}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "run"}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.RequiresSubcommand = false
+
+	mod := cli.AddSubcommand("mod", "", true)
+	mod.AddSubcommand("init", "", true).AddPrimaryArg("name", "", true)
+	mod.AddSubcommand("update", "", true)
+
+	var called string
+	handlers := map[string]func(*olive.ArgParseResult) error{
+		"": func(*olive.ArgParseResult) error {
+			called = "root"
+			return nil
+		},
+		"mod init": func(res *olive.ArgParseResult) error {
+			name, _ := res.PrimaryArg()
+			called = "init " + name
+			return nil
+		},
+		"mod": func(*olive.ArgParseResult) error {
+			return errors.New("mod failed")
+		},
+	}
+
+	if err := olive.Dispatch(cli, []string{"olive", "mod", "init", "pkg"}, handlers); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if called != "init pkg" {
+		t.Fatalf("expected handler `mod init` to be called, not `%s`", called)
+	}
+
+	if err := olive.Dispatch(cli, []string{"olive"}, handlers); err != nil || called != "root" {
+		t.Fatalf("expected root handler to be called: %v", err)
+	}
+
+	mod.RequiresSubcommand = false
+	if err := olive.Dispatch(cli, []string{"olive", "mod"}, handlers); err == nil || err.Error() != "mod failed" {
+		t.Fatalf("expected handler error to be returned: %v", err)
+	}
+
+	err := olive.Dispatch(cli, []string{"olive", "mod", "update"}, handlers)
+	if err == nil || err.Error() != "no handler for command `olive mod update`" {
+		t.Fatalf("unexpected missing handler error: %v", err)
+	}
+
+	if err := olive.Dispatch(cli, []string{"olive", "bad"}, handlers); err == nil {
+		t.Fatal("missing parse error")
+	}
+}
+
+func TestMultipleSeparators(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddStringArg("filter", "f", "", false)
+	cli.AddIntArg("int", "i", "", false)
+	cli.AddFloatArg("float", "fl", "", false)
+	cli.AddSelectorArg("sel", "s", "", false, []string{"a", "a=b"})
+	cli.AddStringListArg("list", "l", "", false)
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "--filter=a=b", "-s=a=b", "--list=x=1,y=2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(result.Arguments, map[string]interface{}{
+		"filter": "a=b",
+		"sel":    "a=b",
+		"list":   []string{"x=1", "y=2"},
+	}) {
+		t.Fatalf("unexpected argument values: %v", result.Arguments)
+	}
+
+	cases := map[string]string{
+		"--int=1=2":    `argument "int" value "1=2" must be an integer`,
+		"-fl=1.5=2":    `argument "float" value "1.5=2" must be a number`,
+		"--sel=a=c":    "`a=c` is not a valid value for argument [sel]",
+		"--filter==ab": "",
+	}
+
+	for input, msg := range cases {
+		_, err := olive.ParseArgs(cli, []string{"olive", input})
+		if msg == "" {
+			if err != nil {
+				t.Fatalf("unexpected error for `%s`: %s", input, err.Error())
+			}
+		} else if msg = "argument 1: " + msg; err == nil || err.Error() != msg {
+			t.Fatalf("expected error `%s` for `%s`, not `%v`", msg, input, err)
+		}
+	}
+}
+
+func TestLenientParse(t *testing.T) {
+	monkey.Patch(os.Exit, func(int) {
+		t.Fatal("lenient parsing should never exit")
+	})
+
+	defer monkey.Unpatch(os.Exit)
+
+	monkey.Patch(fmt.Println, func(a ...interface{}) (int, error) {
+		return 0, nil
+	})
+
+	defer monkey.Unpatch(fmt.Println)
+
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddSubcommand("build", "", true).AddPrimaryArg("package", "", true)
+
+	result, err := olive.LenientParse(cli, []string{"olive", "build", "--help", "--bogus"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if _, res, ok := result.Subcommand(); !ok || !res.HasFlag("help") {
+		t.Fatal("missing help flag on `build`")
+	}
+
+	if !result.Halted() {
+		t.Fatal("expected the result to be marked as halted by help")
+	}
+
+	result, err = olive.LenientParse(cli, []string{"olive", "build", "pkg"})
+	if err != nil || result.Halted() {
+		t.Fatalf("expected a complete result, got %v", err)
+	}
+
+	if _, err := olive.LenientParse(cli, []string{"olive", "--bogus"}); err == nil {
+		t.Fatal("missing unknown flag error")
+	}
+}
+
+func TestExitFunc(t *testing.T) {
+	monkey.Patch(fmt.Println, func(a ...interface{}) (int, error) {
+		return 0, nil
+	})
+
+	defer monkey.Unpatch(fmt.Println)
+
+	cli := olive.NewCLI("olive", "", true)
+	cli.RequiresSubcommand = false
+	sub := cli.AddSubcommand("sub", "", true)
+
+	exitCode := -1
+	cli.ExitFunc = func(code int) {
+		exitCode = code
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "sub", "-h"}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if exitCode != 0 {
+		t.Fatalf("expected exit code `0`, not `%d`", exitCode)
+	}
+
+	// only the exit function of the initial command is used
+	sub.ExitFunc = func(int) {
+		t.Fatal("subcommand exit function should not be called")
+	}
+
+	exitCode = -1
+	if _, err := olive.ParseArgs(cli, []string{"olive", "sub", "-h"}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if exitCode != 0 {
+		t.Fatalf("expected exit code `0`, not `%d`", exitCode)
+	}
+}
+
+func TestArgExamples(t *testing.T) {
+	cli := olive.NewCLI("olive", "", false)
+	cli.AddStringArg("output", "o", "The output path", false).SetExample("build/app")
+	cli.AddStringArg("input", "i", "", false).SetExample("src/main.go")
+
+	help := cli.HelpMessage()
+
+	lines := strings.Split(help, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "-o,") {
+			if !strings.HasSuffix(line, "The output path") {
+				t.Fatalf("expected description on the entry line:\n%s", help)
+			}
+
+			example := lines[i+1]
+			if strings.TrimSpace(example) != "e.g. --output=build/app" || strings.Index(example, "e.g.") != strings.Index(line, "The") {
+				t.Fatalf("expected aligned example beneath description:\n%s", help)
+			}
+		}
+	}
+
+	if !strings.Contains(help, "--input    e.g. --input=src/main.go") {
+		t.Fatalf("expected example in place of empty description:\n%s", help)
+	}
+}
+
+func TestSelectorValueDescriptions(t *testing.T) {
+	cli := olive.NewCLI("olive", "", false)
+
+	sea := cli.AddSelectorArg("log", "l", "The log level", false, []string{"debug", "info", "warn"})
+	sea.SetValueDescriptions(map[string]string{
+		"debug": "Log everything",
+		"warn":  "Only log warnings and errors",
+	})
+
+	help := cli.HelpMessage()
+	if !strings.Contains(help, "The log level\n") ||
+		!strings.Contains(help, "  debug  Log everything\n") ||
+		!strings.Contains(help, "  warn   Only log warnings and errors\n") ||
+		strings.Contains(help, "  info ") {
+		t.Fatalf("expected value descriptions in help:\n%s", help)
+	}
+
+	data, err := cli.HelpJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !strings.Contains(string(data), `"valueDescriptions":{"debug":"Log everything","warn":"Only log warnings and errors"}`) {
+		t.Fatalf("expected value descriptions in help JSON: %s", string(data))
+	}
+
+	logFatalCount := 0
+	monkey.Patch(log.Fatalf, func(format string, v ...interface{}) {
+		logFatalCount++
+	})
+
+	defer monkey.Unpatch(log.Fatalf)
+
+	sea.SetValueDescriptions(map[string]string{"error": "Only log errors"})
+
+	if logFatalCount != 1 {
+		t.Fatalf("expected `1` fatal error; received `%d`", logFatalCount)
+	}
+}
+
+func TestInheritDefault(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddStringArg("config", "c", "", false).SetDefaultValue("olive.json")
+
+	build := cli.AddSubcommand("build", "", true)
+	bc := build.AddStringArg("config", "bc", "", false)
+	bc.SetDefaultValue("build.json")
+	bc.InheritDefault()
+
+	run := cli.AddSubcommand("run", "", true)
+	run.AddStringArg("config", "rc", "", false).SetDefaultValue("run.json")
+
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"olive", "build"}, "olive.json"},
+		{[]string{"olive", "build", "-c=root.json"}, "root.json"},
+		{[]string{"olive", "build", "-bc=own.json"}, "own.json"},
+		{[]string{"olive", "run", "-c=root.json"}, "run.json"},
+	}
+
+	for _, c := range cases {
+		result, err := olive.ParseArgs(cli, c.args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		_, res, _ := result.Subcommand()
+		if res.Arguments["config"].(string) != c.expected {
+			t.Fatalf("expected `%s` for %v, not `%s`", c.expected, c.args, res.Arguments["config"].(string))
+		}
+	}
+}
+
+func TestParserPool(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	build := cli.AddSubcommand("build", "", true)
+	build.AddIntArg("jobs", "j", "", false)
+	build.AddPrimaryArg("package", "", false)
+
+	pool := olive.NewParserPool(cli)
+
+	result, err := pool.ParseArgs([]string{"olive", "build", "-v", "-j=4", "pkg"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	_, res, _ := result.Subcommand()
+	if !result.HasFlag("verbose") || res.Arguments["jobs"].(int) != 4 {
+		t.Fatal("expected the pooled result to match the arguments")
+	}
+
+	pool.Release(result)
+
+	if _, err := pool.ParseArgs([]string{"olive", "build", "-x"}); err == nil {
+		t.Fatal("expected an error for an unknown flag")
+	}
+
+	result, err = pool.ParseArgs([]string{"olive", "build"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	_, res, _ = result.Subcommand()
+	if result.HasFlag("verbose") {
+		t.Fatal("expected a reused result not to keep old flags")
+	}
+
+	if _, ok := res.Arguments["jobs"]; ok {
+		t.Fatal("expected a reused result not to keep old arguments")
+	}
+
+	if arg, ok := res.PrimaryArg(); ok {
+		t.Fatalf("expected a reused result not to keep the old primary argument, got `%s`", arg)
+	}
+}
+
+// TestParserPoolConcurrent is intended to be run with `-race`
+func TestParserPoolConcurrent(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.UseInvokedName = true
+	cli.AddFlag("verbose", "v", "")
+	build := cli.AddSubcommand("build", "", true)
+	build.AddIntArg("jobs", "j", "", false)
+
+	pool := olive.NewParserPool(cli)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				args := []string{fmt.Sprintf("/usr/bin/olive-%d", i), "build", fmt.Sprintf("-j=%d", j)}
+
+				result, err := pool.ParseArgs(args)
+				if err != nil {
+					t.Errorf("unexpected error: %s", err.Error())
+					return
+				}
+
+				if _, res, _ := result.Subcommand(); res.Arguments["jobs"].(int) != j {
+					t.Errorf("expected `%d` jobs, got `%v`", j, res.Arguments["jobs"])
+				}
+
+				pool.Release(result)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if !strings.Contains(cli.HelpMessage(), "    olive <command>") {
+		t.Fatalf("parsing should not change the usage line of the CLI:\n%s", cli.HelpMessage())
+	}
+}
+
+func benchmarkCLI() *olive.Command {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	build := cli.AddSubcommand("build", "", true)
+	build.AddIntArg("jobs", "j", "", false)
+	build.AddStringArg("output", "o", "", false).SetDefaultValue("out")
+	build.AddPrimaryArg("package", "", false)
+	return cli
+}
+
+var benchmarkArgs = []string{"olive", "build", "-v", "-j=4", "pkg"}
+
+func BenchmarkParseArgs(b *testing.B) {
+	cli := benchmarkCLI()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := olive.ParseArgs(cli, benchmarkArgs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParserPool(b *testing.B) {
+	pool := olive.NewParserPool(benchmarkCLI())
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		result, err := pool.ParseArgs(benchmarkArgs)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		pool.Release(result)
+	}
+}
+
+func TestLazyValidation(t *testing.T) {
+	exists := false
+	checkExists := func(string) error {
+		if !exists {
+			return errors.New("file does not exist")
+		}
+
+		return nil
+	}
+
+	cli := olive.NewCLI("olive", "", true)
+	config := cli.AddStringArg("config", "c", "", false)
+	config.SetValidator(checkExists)
+	config.SetLazyValidation()
+	config.SetDefaultValue("olive.json")
+
+	eager := cli.AddStringArg("log", "l", "", false)
+	eager.SetValidator(checkExists)
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-l=out.log"}); err == nil {
+		t.Fatal("expected an error for an eagerly validated argument")
+	}
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "-c=build.json"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if result.Arguments["config"].(string) != "build.json" {
+		t.Fatal("expected the lazily validated value to be stored")
+	}
+
+	if err := result.Validate("config"); err == nil || err.Error() != `argument "config" rejected value "build.json": file does not exist` {
+		t.Fatalf("expected a deferred validation error, got %v", err)
+	}
+
+	exists = true
+	if err := result.Validate("config"); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	exists = false
+	result, err = olive.ParseArgs(cli, []string{"olive"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if err := result.Validate("config"); err == nil {
+		t.Fatal("expected the default value to be validated lazily")
+	}
+
+	if err := result.Validate("log"); err != nil {
+		t.Fatalf("expected no error for an argument without a value, got %s", err.Error())
+	}
+}
+
+func TestBindVars(t *testing.T) {
+	var (
+		verbose bool
+		jobs    = 1
+		ratio   float64
+		output  = "out"
+	)
+
+	cli := olive.NewCLI("olive", "", true)
+	cli.BindFlagVar(&verbose, "verbose", "v", "")
+	build := cli.AddSubcommand("build", "", true)
+	build.BindIntVar(&jobs, "jobs", "j", "")
+	build.BindFloatVar(&ratio, "ratio", "r", "")
+	build.BindStringVar(&output, "output", "o", "")
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "build", "-v", "-j=4", "-r=0.5"}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !verbose || jobs != 4 || ratio != 0.5 {
+		t.Fatalf("expected the bound variables to be set, got %v, %d, %f", verbose, jobs, ratio)
+	}
+
+	if output != "out" {
+		t.Fatalf("expected an unset argument to keep its initial value, got `%s`", output)
+	}
+
+	jobs = 1
+	if _, err := olive.ParseArgs(cli, []string{"olive", "build", "-j=8", "-x"}); err == nil {
+		t.Fatal("expected an error for an unknown flag")
+	}
+
+	if jobs != 1 {
+		t.Fatal("expected the bound variables not to be set when parsing fails")
+	}
+}
+
+func TestValueBeforePrimaryArg(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	tail := cli.AddSubcommand("tail", "", true)
+	tail.AddIntArg("lines", "n", "", false)
+	tail.AddPrimaryArg("file", "", true)
+
+	inputs := [][]string{
+		{"olive", "tail", "-n", "10", "file.txt"},
+		{"olive", "tail", "file.txt", "-n", "10"},
+		{"olive", "tail", "-n=10", "file.txt"},
+		{"olive", "tail", "--lines", "10", "file.txt"},
+	}
+
+	for _, input := range inputs {
+		result, err := olive.ParseArgs(cli, input)
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %s", input, err.Error())
+		}
+
+		_, res, _ := result.Subcommand()
+		if res.Arguments["lines"].(int) != 10 {
+			t.Fatalf("expected 10 lines for %v, got %v", input, res.Arguments["lines"])
+		}
+
+		if arg, _ := res.PrimaryArg(); arg != "file.txt" {
+			t.Fatalf("expected the primary argument `file.txt` for %v, got `%s`", input, arg)
+		}
+	}
+}
+
+func TestExecute(t *testing.T) {
+	var code int
+	var out strings.Builder
+
+	cli := olive.NewCLI("olive", "", true)
+	cli.ExitFunc = func(c int) { code = c }
+	cli.ErrorWriter = &out
+
+	build := cli.AddSubcommand("build", "", true)
+	build.AddIntArg("jobs", "j", "", false)
+	build.SetHandler(func(_ context.Context, res *olive.ArgParseResult) error {
+		if res.Arguments["jobs"].(int) < 1 {
+			return errors.New("at least one job is required")
+		}
+
+		return nil
+	})
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	cases := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"olive", "build", "-j=2"}, 0, ""},
+		{[]string{"olive", "build", "-j=0"}, 1, "error: at least one job is required\n"},
+		{[]string{"olive", "build", "-x"}, 2, "error: argument 2: unknown flag by short name: `x`\n\nUsage:\n\n    build [-j|--jobs=<int>] [-h|--help]\n"},
+	}
+
+	for _, c := range cases {
+		code = -1
+		out.Reset()
+		os.Args = c.args
+
+		cli.Execute()
+
+		if code != c.code {
+			t.Fatalf("expected exit code %d for %v, got %d", c.code, c.args, code)
+		}
+
+		if out.String() != c.expected {
+			t.Fatalf("expected output %q for %v, got %q", c.expected, c.args, out.String())
+		}
+	}
+
+	cli.ParseErrorExitCode = 64
+	os.Args = []string{"olive", "run"}
+	cli.Execute()
+
+	if code != 64 {
+		t.Fatalf("expected the configured exit code, got %d", code)
+	}
+}
+
+func TestBindStruct(t *testing.T) {
+	type mode string
+
+	var opts struct {
+		Verbose bool     `olive:"verbose,short=v"`
+		Jobs    int      `olive:"jobs,short=j,min=1,max=64,default=4" desc:"number of jobs"`
+		Ratio   float64  `olive:",short=r,max=1"`
+		Mode    mode     `olive:"mode,short=m,oneof=debug|release,default=debug"`
+		Paths   []string `olive:"paths,short=p,default=src|lib"`
+		Ignored string
+	}
+
+	cli := olive.NewCLI("olive", "", true)
+	if err := cli.BindStruct(&opts); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !cli.HasArgDefined("ratio") || cli.HasArgDefined("ignored") {
+		t.Fatal("expected only tagged fields to be bound")
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-v", "-m=release", "-r=0.5"}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !opts.Verbose || opts.Jobs != 4 || opts.Ratio != 0.5 || opts.Mode != "release" || !reflect.DeepEqual(opts.Paths, []string{"src", "lib"}) {
+		t.Fatalf("expected the fields to be set, got %+v", opts)
+	}
+
+	for _, args := range [][]string{{"olive", "-j=0"}, {"olive", "-j=65"}, {"olive", "-r=1.5"}, {"olive", "-m=test"}} {
+		if _, err := olive.ParseArgs(cli, args); err == nil {
+			t.Fatalf("expected an error for %v", args)
+		}
+	}
+
+	var bad struct {
+		A int    `olive:"a,min=x"`
+		B string `olive:"b,min=1"`
+		C int    `olive:"c,oneof=1|2"`
+		D int    `olive:"d,default=1,min=2"`
+		E int    `olive:"e,color=red"`
+		F bool   `olive:"f,default=true"`
+		G uint   `olive:"g"`
+		H int    `olive:"h"`
+	}
+
+	err := olive.NewCLI("olive", "", true).BindStruct(&bad)
+	if errs, ok := err.(olive.ConfigErrors); !ok || len(errs) != 7 {
+		t.Fatalf("expected 7 configuration errors, got %v", err)
+	}
+
+	if err := cli.BindStruct(opts); err == nil {
+		t.Fatal("expected an error when binding a struct that is not a pointer")
+	}
+}
+
+func TestLint(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	cli.AddIntArg("int", "i", "", false)
+	cli.AddIntArg("integer", "int", "", false)
+
+	build := cli.AddSubcommand("build", "", true)
+	build.AddFlag("optimize", "o", "")
+	build.AddStringArg("output", "out", "", false)
+
+	errs := cli.Lint()
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 lint errors, got %v", errs)
+	}
+
+	if errs[0].Error() != "short names `i` and `int` of command `olive` are ambiguous: one is a prefix of the other" {
+		t.Fatalf("unexpected lint error: %s", errs[0].Error())
+	}
+
+	if errs[1].Error() != "short names `o` and `out` of command `build` are ambiguous: one is a prefix of the other" {
+		t.Fatalf("unexpected lint error: %s", errs[1].Error())
+	}
+
+	if errs := olive.NewCLI("olive", "", true).Lint(); len(errs) != 0 {
+		t.Fatalf("expected no lint errors, got %v", errs)
+	}
+
+	cli = olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "", "")
+	cli.AddStringArg("output", "", "", false)
+
+	if errs := cli.Lint(); len(errs) != 0 {
+		t.Fatalf("expected no lint errors for options without short names, got %v", errs)
+	}
+
+	cli = olive.NewCLI("olive", "", true)
+	cli.AddFlag("output-all", "o", "")
+	cli.AddStringArg("output", "o", "", false)
+
+	errs = cli.Lint()
+	if len(errs) != 1 || errs[0].Error() != "short name `o` of command `olive` is ambiguous: it is used by both a flag and an argument" {
+		t.Fatalf("expected a lint error for a shared short name, got %v", errs)
+	}
+
+	cli = olive.NewCLI("olive", "", true)
+	sea := cli.AddSelectorArg("sel", "s", "", false, []string{"val1", "val2", "badVal"})
+	sea.SetValidator(func(x string) error {
+		if x == "badVal" {
+			return errors.New("bad val")
+		}
+
+		return nil
+	})
+
+	if errs := cli.Lint(); len(errs) != 0 {
+		t.Fatalf("expected no lint errors for a partially rejected selector, got %v", errs)
+	}
+
+	sea.SetValidator(func(string) error { return errors.New("bad val") })
+
+	errs = cli.Lint()
+	if len(errs) != 1 || errs[0].Error() != "selector argument `sel` of command `olive` rejects all of its possible values" {
+		t.Fatalf("expected a lint error for a fully rejected selector, got %v", errs)
+	}
+}
+
+func TestRunMain(t *testing.T) {
+	var code int
+	var out strings.Builder
+
+	cli := olive.NewCLI("wc", "", true)
+	cli.ExitFunc = func(c int) { code = c }
+	cli.ErrorWriter = &out
+	cli.AddFlag("lines", "l", "")
+	cli.AddPrimaryArg("file", "", true)
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	var file string
+	run := func(res *olive.ArgParseResult) error {
+		file, _ = res.PrimaryArg()
+		if !res.HasFlag("lines") {
+			return errors.New("only lines can be counted")
+		}
+
+		return nil
+	}
+
+	os.Args = []string{"wc", "-l", "main.go"}
+	cli.RunMain(run)
+	if code != 0 || file != "main.go" || out.Len() != 0 {
+		t.Fatalf("expected a successful run, got code %d and output %q", code, out.String())
+	}
+
+	os.Args = []string{"wc", "main.go"}
+	cli.RunMain(run)
+	if code != 1 || out.String() != "error: only lines can be counted\n" {
+		t.Fatalf("expected a run error, got code %d and output %q", code, out.String())
+	}
+
+	out.Reset()
+	os.Args = []string{"wc"}
+	cli.RunMain(run)
+	if code != 2 || !strings.HasPrefix(out.String(), "error: command \"wc\" requires argument <file>\n\nUsage:\n\n") {
+		t.Fatalf("expected a parse error, got code %d and output %q", code, out.String())
+	}
+}
+
+func TestHelpText(t *testing.T) {
+	cli := olive.NewCLI("olive", "Das Olive-Werkzeug", true)
+	cli.HelpText = &olive.HelpText{
+		Usage:           "Verwendung",
+		Commands:        "Befehle",
+		PrimaryArgument: "Hauptargument",
+		Arguments:       "Argumente",
+		Flags:           "Schalter",
+		Command:         "befehl",
+		HelpFlag:        "Hilfe anzeigen",
+		VersionFlag:     "Version anzeigen",
+		DisableFlag:     "--%s deaktivieren",
+		Example:         "z.B.",
+		Default:         "Standard",
+	}
+	cli.SetVersion("1.0.0")
+
+	build := cli.AddSubcommand("build", "Baut ein Paket", true)
+	build.AddStringArg("output", "o", "Ausgabe", false).SetExample("out")
+	build.AddStringArg("profile", "p", "Profil", false).SetDefaultValue("dev")
+	build.AddStringArg("cache", "c", "Cache", false).SetDisableFlag("no-cache", "nc")
+	build.AddPrimaryArg("paket", "", false)
+
+	help := cli.HelpMessage()
+	for _, label := range []string{"Verwendung:", "Befehle:", "<befehl>", "Schalter:", "Hilfe anzeigen", "Version anzeigen"} {
+		if !strings.Contains(help, label) {
+			t.Fatalf("expected `%s` in help message:\n%s", label, help)
+		}
+	}
+
+	help = build.HelpMessage()
+	for _, label := range []string{"Hauptargument:", "Argumente:", "z.B. --output=out", "Hilfe anzeigen", "Profil (Standard: dev)", "--cache deaktivieren"} {
+		if !strings.Contains(help, label) {
+			t.Fatalf("expected `%s` in help message:\n%s", label, help)
+		}
+	}
+
+	if strings.Contains(help, "Get help") || strings.Contains(help, "Usage") || strings.Contains(help, "default") || strings.Contains(help, "Disable") {
+		t.Fatalf("expected no default text in help message:\n%s", help)
+	}
+}
+
+func TestTerminator(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.RequiresSubcommand = false
+	cli.AddFlag("verbose", "v", "")
+
+	run := cli.AddSubcommand("run", "", true)
+	run.AddPrimaryArg("script", "", true)
+
+	mod := cli.AddSubcommand("mod", "", true)
+	update := mod.AddSubcommand("update", "", true)
+	update.AddFlag("all", "a", "")
+
+	cases := []struct {
+		args     []string
+		path     []string
+		primary  string
+		trailing []string
+	}{
+		{[]string{"olive", "--", "run", "-v"}, nil, "", []string{"run", "-v"}},
+		{[]string{"olive", "run", "script.sh", "--", "--flag-for-script"}, []string{"run"}, "script.sh", []string{"--flag-for-script"}},
+		{[]string{"olive", "run", "-v", "--", "-script.sh", "-x"}, []string{"run"}, "-script.sh", []string{"-x"}},
+		{[]string{"olive", "mod", "update", "-a", "--", "--", "b"}, []string{"mod", "update"}, "", []string{"--", "b"}},
+	}
+
+	for _, c := range cases {
+		result, err := olive.ParseArgs(cli, c.args)
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %s", c.args, err.Error())
+		}
+
+		var path []string
+		res := result
+		for {
+			name, subres, ok := res.Subcommand()
+			if !ok {
+				break
+			}
+
+			path = append(path, name)
+			res = subres
+		}
+
+		if !reflect.DeepEqual(path, c.path) {
+			t.Fatalf("expected the command path %v for %v, got %v", c.path, c.args, path)
+		}
+
+		if primary, _ := res.PrimaryArg(); primary != c.primary {
+			t.Fatalf("expected the primary argument `%s` for %v, got `%s`", c.primary, c.args, primary)
+		}
+
+		if !reflect.DeepEqual(res.TrailingArgs(), c.trailing) {
+			t.Fatalf("expected the trailing arguments %v for %v, got %v", c.trailing, c.args, res.TrailingArgs())
+		}
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "run", "--"}); err == nil {
+		t.Fatal("expected an error for a missing primary argument after the terminator")
+	}
+}
+
+func TestResultCommand(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	mod := cli.AddSubcommand("mod", "Manage modules", true)
+	update := mod.AddSubcommand("update", "Update modules", true)
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "mod", "update"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if result.Command() != cli {
+		t.Fatal("expected the initial result to belong to the CLI")
+	}
+
+	_, modRes, _ := result.Subcommand()
+	_, updateRes, _ := modRes.Subcommand()
+	if modRes.Command() != mod || updateRes.Command() != update {
+		t.Fatal("expected each subcommand result to belong to its command")
+	}
+
+	if updateRes.Command().Description != "Update modules" {
+		t.Fatalf("unexpected description: %s", updateRes.Command().Description)
+	}
+}
+
+func TestFlagHasAction(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	plain := cli.AddFlag("verbose", "v", "")
+	version := cli.AddFlag("version", "V", "")
+	version.SetAction(func() {})
+
+	for _, flag := range cli.Flags() {
+		if expected := flag != plain; flag.HasAction() != expected {
+			t.Fatalf("expected HasAction of flag `%s` to be %v", flag.Name(), expected)
+		}
+	}
+}
+
+func TestTriStateArg(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	color := cli.AddTriStateArg("color", "c", "", false)
+	color.SetDefaultValue(olive.TriStateAuto)
+
+	pager := cli.AddTriStateArg("pager", "p", "", false)
+	pager.SetValidator(func(ts olive.TriState) error {
+		if ts.IsAuto() {
+			return errors.New("the pager cannot be detected")
+		}
+
+		return nil
+	})
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "-p=off"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !result.Arguments["color"].(olive.TriState).IsAuto() || !result.Arguments["pager"].(olive.TriState).IsOff() {
+		t.Fatalf("unexpected tri-state values: %v", result.Arguments)
+	}
+
+	result, err = olive.ParseArgs(cli, []string{"olive", "-c=on"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if ts := result.Arguments["color"].(olive.TriState); !ts.IsOn() || ts.String() != "on" {
+		t.Fatalf("expected `on`, got `%s`", ts)
+	}
+
+	for _, args := range [][]string{{"olive", "-c=yes"}, {"olive", "-p=auto"}} {
+		if _, err := olive.ParseArgs(cli, args); err == nil {
+			t.Fatalf("expected an error for %v", args)
+		}
+	}
+
+	if !strings.Contains(cli.HelpMessage(), "[-c|--color=<on|off|auto>]") {
+		t.Fatalf("expected the tri-state values in the usage line:\n%s", cli.HelpMessage())
+	}
+
+	var opts struct {
+		Color olive.TriState `olive:"color,default=off"`
+	}
+
+	cli = olive.NewCLI("olive", "", true)
+	if err := cli.BindStruct(&opts); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive"}); err != nil || !opts.Color.IsOff() {
+		t.Fatalf("expected the bound tri-state default, got %s (%v)", opts.Color, err)
+	}
+}
+
+func TestOptionalValue(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddSelectorArg("log", "l", "", false, []string{"info", "debug"}).SetOptionalValue("info")
+	cli.AddPrimaryArg("file", "", false)
+
+	cases := []struct {
+		args []string
+		log  string
+		file string
+	}{
+		{[]string{"olive", "--log"}, "info", ""},
+		{[]string{"olive", "--log=debug"}, "debug", ""},
+		{[]string{"olive", "--log", "debug"}, "info", "debug"},
+		{[]string{"olive", "-l", "main.go"}, "info", "main.go"},
+		{[]string{"olive", "main.go", "-l=debug"}, "debug", "main.go"},
+	}
+
+	for _, c := range cases {
+		result, err := olive.ParseArgs(cli, c.args)
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %s", c.args, err.Error())
+		}
+
+		if result.Arguments["log"].(string) != c.log {
+			t.Fatalf("expected the log level `%s` for %v, got `%s`", c.log, c.args, result.Arguments["log"])
+		}
+
+		if file, _ := result.PrimaryArg(); file != c.file {
+			t.Fatalf("expected the primary argument `%s` for %v, got `%s`", c.file, c.args, file)
+		}
+	}
+
+	if !strings.Contains(cli.HelpMessage(), "[-l|--log[=<info|debug>]]") {
+		t.Fatalf("expected the optional value in the usage line:\n%s", cli.HelpMessage())
+	}
+}
+
+func TestTokenErrors(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	build := cli.AddSubcommand("build", "", true)
+	build.AddFlag("verbose", "v", "")
+	build.AddStringArg("output", "o", "", false)
+
+	cases := []struct {
+		args  []string
+		index int
+		token string
+		msg   string
+	}{
+		{[]string{"olive", "build", "-v", "--bogus"}, 3, "--bogus", "argument 3: unknown flag: `bogus`"},
+		{[]string{"olive", "test"}, 1, "test", "argument 1: unknown subcommand: `test`"},
+		{[]string{"olive", "build", "-o", "-v"}, 3, "-v", "argument 3: missing value for argument `output` (values starting with `-` must be given as `--output=-v`)"},
+		{[]string{"olive", "build", "-v", "-o"}, 3, "-o", "argument 3: missing value for argument `output`"},
+	}
+
+	for _, c := range cases {
+		_, err := olive.ParseArgs(cli, c.args)
+
+		var te *olive.TokenError
+		if !errors.As(err, &te) {
+			t.Fatalf("expected a token error for %v, got %v", c.args, err)
+		}
+
+		if te.Index != c.index || te.Token != c.token || te.Error() != c.msg {
+			t.Fatalf("expected `%s` at %d (`%s`) for %v, got `%s` at %d (`%s`)", c.msg, c.index, c.token, c.args, te.Error(), te.Index, te.Token)
+		}
+
+		if c.args[te.Index] != te.Token {
+			t.Fatalf("expected the index to refer to the original arguments")
+		}
+	}
+}
+
+func TestDisableFlag(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cache := cli.AddStringArg("cache", "c", "", true)
+	cache.SetDefaultValue(".cache")
+	cache.SetDisableFlag("no-cache", "nc")
+
+	result, err := olive.ParseArgs(cli, []string{"olive"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if result.Arguments["cache"].(string) != ".cache" {
+		t.Fatal("expected the default value without the disable flag")
+	}
+
+	result, err = olive.ParseArgs(cli, []string{"olive", "--no-cache"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if _, ok := result.Arguments["cache"]; ok || !result.HasFlag("no-cache") {
+		t.Fatal("expected the disable flag to clear the argument")
+	}
+
+	if len(result.MissingRequired()) != 0 {
+		t.Fatalf("expected a disabled argument not to be missing, got %v", result.MissingRequired())
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "-c=dir", "-nc"})
+	if err == nil || err.Error() != "argument `cache` cannot be given together with `--no-cache`" {
+		t.Fatalf("expected an error for both the argument and its disable flag, got %v", err)
+	}
+
+	if !strings.Contains(cli.HelpMessage(), "Disable --cache") {
+		t.Fatalf("expected the disable flag in help:\n%s", cli.HelpMessage())
+	}
+}
+
+func TestParseArgsInto(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	build := cli.AddSubcommand("build", "", true)
+	build.AddIntArg("jobs", "j", "", false).SetDefaultValue(1)
+	build.AddStringArg("output", "o", "", true)
+	build.AddFlag("release", "r", "")
+
+	// the base is loaded from a "configuration file"
+	base, err := olive.ParseArgs(cli, []string{"olive", "build", "-v", "-r", "-j=4", "-o=out"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	result, err := olive.ParseArgsInto(cli, []string{"olive", "build", "-o=bin", "-r"}, base)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	_, res, _ := result.Subcommand()
+	if !result.HasFlag("verbose") || !res.HasFlag("release") {
+		t.Fatal("expected the base flags to be kept")
+	}
+
+	if res.Arguments["jobs"].(int) != 4 || res.Arguments["output"].(string) != "bin" {
+		t.Fatalf("expected the given values to override the base values, got %v", res.Arguments)
+	}
+
+	if _, baseRes, _ := base.Subcommand(); baseRes.Arguments["output"].(string) != "out" {
+		t.Fatal("expected the base result not to be modified")
+	}
+
+	seed := &olive.ArgParseResult{Arguments: map[string]interface{}{"jobs": 8}}
+	result, err = olive.ParseArgsInto(cli, []string{"olive", "build", "-o=bin"}, seed)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if _, res, _ := result.Subcommand(); res.Arguments["jobs"].(int) != 1 {
+		t.Fatal("expected the base values of the initial command not to apply to subcommands")
+	}
+
+	if _, ok := result.Arguments["jobs"]; ok {
+		t.Fatal("expected base values of unknown arguments to be ignored")
+	}
+}
+
+func TestPriorityFlags(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.ExitFunc = func(int) {}
+
+	versionShown := false
+	version := cli.AddFlag("version", "V", "")
+	version.SetAction(func() { versionShown = true })
+	version.SetPriority()
+
+	build := cli.AddSubcommand("build", "", true)
+	build.AddIntArg("jobs", "j", "", false)
+
+	result, err := olive.LenientParse(cli, []string{"olive", "build", "--bogus", "--help"})
+	if err != nil || result == nil {
+		t.Fatalf("expected help to win over the error, got %v", err)
+	}
+
+	if _, err := olive.LenientParse(cli, []string{"olive", "build", "-j=abc", "-h"}); err != nil {
+		t.Fatalf("expected help to win over the error, got %v", err)
+	}
+
+	if _, err := olive.LenientParse(cli, []string{"olive", "build", "--bogus", "--", "--help"}); err == nil {
+		t.Fatal("expected help after the terminator to be ignored")
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "--bogus", "-V"}); err == nil || !versionShown {
+		t.Fatalf("expected the version action to run, got %v", err)
+	}
+}
+
+func TestDispatchByName(t *testing.T) {
+	cli := olive.NewCLI("box", "", true)
+	ln := cli.AddSubcommand("ln", "", true)
+	ln.AddFlag("symbolic", "s", "")
+	ln.AddPrimaryArg("target", "", true)
+	cli.AddSubcommand("cp", "", true).AddPrimaryArg("source", "", true)
+
+	result, err := cli.DispatchByName([]string{"/usr/bin/ln", "-s", "file"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	name, res, _ := result.Subcommand()
+	if target, _ := res.PrimaryArg(); name != "ln" || !res.HasFlag("symbolic") || target != "file" {
+		t.Fatalf("expected `ln` to be selected by the invoked name, got `%s`", name)
+	}
+
+	result, err = cli.DispatchByName([]string{"box", "cp", "file"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if name, _, _ := result.Subcommand(); name != "cp" {
+		t.Fatalf("expected `cp` to be selected explicitly, got `%s`", name)
+	}
+
+	if _, err := cli.DispatchByName([]string{"ln", "file", "-x"}); err == nil || err.Error() != "argument 2: unknown flag by short name: `x`" {
+		t.Fatalf("expected the error to refer to the original arguments, got %v", err)
+	}
+
+	if _, err := cli.DispatchByName([]string{"mv", "file"}); err == nil || err.Error() != "unknown command: `mv`" {
+		t.Fatalf("expected an error for an unknown invoked name, got %v", err)
+	}
+
+	ln.SetAvailableIf(func(*olive.ArgParseResult) bool { return false })
+	if _, err := cli.DispatchByName([]string{"ln", "file"}); err == nil || err.Error() != "unknown command: `ln`" {
+		t.Fatalf("expected an error for an unavailable invoked name, got %v", err)
+	}
+}
+
+func TestRequireDescriptions(t *testing.T) {
+	cli := olive.NewCLI("olive", "The olive tool", true)
+	cli.AddFlag("verbose", "v", "")
+	build := cli.AddSubcommand("build", "", true)
+	build.AddStringArg("output", "o", "", false)
+	build.AddPrimaryArg("package", "The package", false)
+
+	if errs := cli.Lint(); len(errs) != 0 {
+		t.Fatalf("expected descriptions not to be required by default, got %v", errs)
+	}
+
+	cli.RequireDescriptions = true
+
+	var msgs []string
+	for _, err := range cli.Lint() {
+		msgs = append(msgs, err.Error())
+	}
+
+	expected := []string{
+		"subcommand `build` of command `olive` has no description",
+		"flag `verbose` of command `olive` has no description",
+		"argument `output` of command `build` has no description",
+	}
+
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Fatalf("expected %v, got %v", expected, msgs)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	var out strings.Builder
+
+	cli := olive.NewCLI("olive", "", true)
+	cli.ExitFunc = func(int) {}
+	cli.ErrorWriter = &out
+	cli.JSONErrors = true
+
+	build := cli.AddSubcommand("build", "", true)
+	build.AddFlag("verbose", "v", "")
+	build.AddIntArg("jobs", "j", "", false)
+	build.SetHandler(func(context.Context, *olive.ArgParseResult) error {
+		return errors.New("build failed")
+	})
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"olive", "build", "-v", "--bogus"}, `{"error":"unknown flag: ` + "`bogus`" + `","kind":"unknown_flag","token":"--bogus","index":3}`},
+		{[]string{"olive", "build", "-j=x"}, `{"error":"argument \"jobs\" value \"x\" must be an integer","kind":"invalid_value","token":"-j=x","index":2}`},
+		{[]string{"olive", "build", "-v", "-v"}, `{"error":"flag ` + "`verbose`" + ` set multiple times","kind":"duplicate","token":"-v","index":3}`},
+		{[]string{"olive", "test"}, `{"error":"unknown subcommand: ` + "`test`" + `","kind":"unknown_subcommand","token":"test","index":1}`},
+		{[]string{"olive", "build"}, `{"error":"build failed"}`},
+	}
+
+	for _, c := range cases {
+		out.Reset()
+		os.Args = c.args
+
+		cli.Execute()
+
+		if out.String() != c.expected+"\n" {
+			t.Fatalf("expected %s for %v, got %s", c.expected, c.args, out.String())
+		}
+	}
+
+	_, err := olive.ParseArgs(cli, []string{"olive", "build", "-x"})
+
+	var te *olive.TokenError
+	if !errors.As(err, &te) || te.Kind != olive.KindUnknownFlag {
+		t.Fatalf("expected an unknown flag error, got %v", err)
+	}
+}
+
+func TestDefaultDisplayOnly(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	jobs := cli.AddIntArg("jobs", "j", "", false)
+	jobs.SetDefaultValue(4)
+	jobs.SetDefaultDisplayOnly()
+	cli.AddStringArg("output", "o", "", false).SetDefaultValue("out")
+
+	result, err := olive.ParseArgs(cli, []string{"olive"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if _, ok := result.Arguments["jobs"]; ok || result.WasProvided("jobs") {
+		t.Fatal("expected a display-only default not to be applied")
+	}
+
+	if result.Arguments["output"].(string) != "out" || result.WasProvided("output") {
+		t.Fatal("expected a regular default to be applied but not provided")
+	}
+
+	result, err = olive.ParseArgs(cli, []string{"olive", "-j=2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if result.Arguments["jobs"].(int) != 2 || !result.WasProvided("jobs") {
+		t.Fatal("expected a given value to be provided")
+	}
+
+	if val, ok := jobs.GetDefaultValue(); !ok || val.(int) != 4 {
+		t.Fatal("expected the display-only default to still be defined")
+	}
+
+	data, err := cli.HelpJSON()
+	if err != nil || !strings.Contains(string(data), `"default":4`) {
+		t.Fatalf("expected the display-only default to be displayed, got %s (%v)", data, err)
+	}
+}
+
+func TestHelpHeaderFooter(t *testing.T) {
+	cli := olive.NewCLI("olive", "The olive tool", false)
+	cli.AddFlag("verbose", "v", "Be verbose")
+
+	cli.SetHelpHeader("OLIVE v1.0")
+	cli.SetHelpFooter("See the documentation at https://example.com/olive for more information about every command")
+
+	help := cli.HelpMessage()
+	if !strings.HasPrefix(help, "OLIVE v1.0\n\nThe olive tool\n\nUsage:") {
+		t.Fatalf("expected the header before the description:\n%s", help)
+	}
+
+	if !strings.HasSuffix(help, "Be verbose\n\nSee the documentation at https://example.com/olive for more\ninformation about every command\n") {
+		t.Fatalf("expected the footer after the last section:\n%s", help)
+	}
+}
+
+func TestLintRequiredDefaults(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddStringArg("output", "o", "", true).SetDefaultValue("out")
+	cli.AddIntArg("jobs", "j", "", false).SetDefaultValue(1)
+
+	threads := cli.AddIntArg("threads", "t", "", true)
+	threads.SetDefaultValue(4)
+	threads.SetDefaultDisplayOnly()
+
+	errs := cli.Lint()
+	if len(errs) != 1 || errs[0].Error() != "argument `output` of command `olive` is required but has a default value so it can never be missing" {
+		t.Fatalf("expected a lint error for the required argument with a default, got %v", errs)
+	}
+}
+
+func TestHasFlagRecursive(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	mod := cli.AddSubcommand("mod", "", true)
+	update := mod.AddSubcommand("update", "", true)
+	update.AddFlag("all", "a", "")
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "mod", "update", "-v", "-a"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	_, modRes, _ := result.Subcommand()
+	_, updateRes, _ := modRes.Subcommand()
+
+	if updateRes.HasFlag("verbose") || !updateRes.HasFlagRecursive("verbose") {
+		t.Fatal("expected a global flag to be found from a subcommand result")
+	}
+
+	if result.HasFlag("all") || !result.HasFlagRecursive("all") || !modRes.HasFlagRecursive("all") {
+		t.Fatal("expected a subcommand flag to be found from a parent result")
+	}
+
+	if result.HasFlagRecursive("help") {
+		t.Fatal("expected an unset flag not to be found")
+	}
+}
+
+func TestHelpOnNoArgs(t *testing.T) {
+	code := -1
+
+	cli := olive.NewCLI("olive", "", true)
+	cli.ExitFunc = func(c int) { code = c }
+	cli.AddSubcommand("build", "", true)
+
+	if _, err := olive.ParseArgs(cli, []string{"olive"}); err == nil || code != -1 {
+		t.Fatalf("expected the missing subcommand error by default, got %v", err)
+	}
+
+	cli.HelpOnNoArgs = true
+
+	result, err := olive.ParseArgs(cli, []string{"olive"})
+	if err != nil || result == nil || code != 0 {
+		t.Fatalf("expected help and exit code 0, got %v and %d", err, code)
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-v"}); err == nil {
+		t.Fatal("expected other arguments to be parsed as usual")
+	}
+}
+
+func TestAllowRepeat(t *testing.T) {
+	runs := 0
+
+	cli := olive.NewCLI("olive", "", true)
+	verbose := cli.AddFlag("verbose", "v", "")
+	verbose.SetAllowRepeat()
+	verbose.SetAction(func() { runs++ })
+	cli.AddFlag("quiet", "q", "")
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "-v", "--verbose", "-v"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !result.HasFlag("verbose") || runs != 1 {
+		t.Fatalf("expected a repeated flag to be set once, ran its action %d times", runs)
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-q", "-q"}); err == nil {
+		t.Fatal("expected an error for a repeated flag which does not allow repeats")
+	}
+}
+
+func TestPatternSelectorArg(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	target := cli.AddPatternSelectorArg("target", "t", "", false, `[a-z0-9]+-[a-z0-9]+`)
+	target.SetDefaultValue("linux-amd64")
+
+	result, err := olive.ParseArgs(cli, []string{"olive"})
+	if err != nil || result.Arguments["target"].(string) != "linux-amd64" {
+		t.Fatalf("expected the default value, got %v (%v)", result, err)
+	}
+
+	result, err = olive.ParseArgs(cli, []string{"olive", "-t=windows-arm64"})
+	if err != nil || result.Arguments["target"].(string) != "windows-arm64" {
+		t.Fatalf("expected the given value, got %v (%v)", result, err)
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "-t=x-windows-arm64"})
+	if err == nil || err.Error() != "argument 1: `x-windows-arm64` does not match the pattern `[a-z0-9]+-[a-z0-9]+` of argument [target]" {
+		t.Fatalf("expected a pattern error matching the whole value, got %v", err)
+	}
+
+	if !strings.Contains(cli.HelpMessage(), "[-t|--target=<[a-z0-9]+-[a-z0-9]+>]") {
+		t.Fatalf("expected the pattern in the usage line:\n%s", cli.HelpMessage())
+	}
+}
+
+func TestToArgs(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	cli.AddFloatArg("ratio", "r", "", false)
+
+	build := cli.AddSubcommand("build", "", true)
+	build.AddIntArg("jobs", "j", "", false).SetDefaultValue(1)
+	build.AddStringListArg("tags", "t", "", false)
+	build.AddTriStateArg("color", "c", "", false)
+	build.AddFlag("release", "R", "")
+	build.AddPrimaryArg("package", "", false)
+
+	cases := []struct {
+		args     []string
+		expected []string
+	}{
+		{
+			[]string{"olive", "build", "pkg", "-R", "-v", "-t=a\\,b,c", "-r=0.5", "-c=off"},
+			[]string{"--verbose", "--ratio=0.5", "build", "--release", "--color=off", "--jobs=1", "--tags=a\\,b,c", "pkg"},
+		},
+		{
+			[]string{"olive", "build", "--", "-pkg", "x", "y"},
+			[]string{"build", "--jobs=1", "--", "-pkg", "x", "y"},
+		},
+	}
+
+	for _, c := range cases {
+		result, err := olive.ParseArgs(cli, c.args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		args := result.ToArgs()
+		if !reflect.DeepEqual(args, c.expected) {
+			t.Fatalf("expected %v for %v, got %v", c.expected, c.args, args)
+		}
+
+		reparsed, err := olive.ParseArgs(cli, append([]string{"olive"}, args...))
+		if err != nil {
+			t.Fatalf("unexpected error reparsing %v: %s", args, err.Error())
+		}
+
+		if !reflect.DeepEqual(reparsed.ToArgs(), args) {
+			t.Fatalf("expected %v to parse to the same result, got %v", args, reparsed.ToArgs())
+		}
+	}
+}
+
+func TestToArgsRoundTrip(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	cli.AddStringArg("name", "n", "", false).SetDefaultValue("")
+	cli.AddStringListArg("tags", "t", "", false).SetDefaultValue([]string{})
+
+	payload := cli.AddStringArg("payload", "p", "", false)
+	payload.SetDefaultValue(`{"n": 1}`)
+	payload.SetCodec(func(s string) (interface{}, error) {
+		var v map[string]interface{}
+		err := json.Unmarshal([]byte(s), &v)
+		return v, err
+	})
+
+	// the flag of the subcommand shadows the flag of the initial command
+	build := cli.AddSubcommand("build", "", true)
+	build.AddFlag("verbose", "v", "")
+	build.AddStringArg("meta", "m", "", false).SetCodec(func(s string) (interface{}, error) {
+		var v []interface{}
+		err := json.Unmarshal([]byte(s), &v)
+		return v, err
+	})
+
+	// loaded defaults are rendered as they were given as well
+	path := filepath.Join(t.TempDir(), "defaults.json")
+	if err := os.WriteFile(path, []byte(`{"build": {"meta": "[1, 2]"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := olive.LoadDefaults(cli, path); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	cases := []struct {
+		args     []string
+		expected []string
+	}{
+		{
+			[]string{"olive", "-v", "build"},
+			[]string{"--verbose", "--name=", `--payload={"n": 1}`, "build", "--meta=[1, 2]"},
+		},
+		{
+			[]string{"olive", "build", "-v", "-t=a,,", `-p={"n": 2}`},
+			[]string{"--name=", `--payload={"n": 2}`, "--tags=a,,", "build", "--verbose", "--meta=[1, 2]"},
+		},
+	}
+
+	for _, c := range cases {
+		result, err := olive.ParseArgs(cli, c.args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		args := result.ToArgs()
+		if !reflect.DeepEqual(args, c.expected) {
+			t.Fatalf("expected %v for %v, got %v", c.expected, c.args, args)
+		}
+
+		reparsed, err := olive.ParseArgs(cli, append([]string{"olive"}, args...))
+		if err != nil {
+			t.Fatalf("unexpected error reparsing %v: %s", args, err.Error())
+		}
+
+		_, res, _ := result.Subcommand()
+		_, reres, _ := reparsed.Subcommand()
+
+		if !reflect.DeepEqual(reparsed.Arguments, result.Arguments) || !reflect.DeepEqual(reres.Arguments, res.Arguments) || reparsed.HasFlag("verbose") != result.HasFlag("verbose") || reres.HasFlag("verbose") != res.HasFlag("verbose") {
+			t.Fatalf("expected %v to parse to the same result as %v", args, c.args)
+		}
+	}
+}
+
+func TestHiddenArgs(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddIntArg("jobs", "j", "Number of jobs", false)
+	cli.AddIntArg("debug-port", "dp", "Debug port", true).SetHidden()
+
+	help := cli.HelpMessage()
+	if strings.Contains(help, "debug-port") || !strings.Contains(help, "jobs") {
+		t.Fatalf("expected only the hidden argument to be omitted from help:\n%s", help)
+	}
+
+	data, err := cli.HelpJSON()
+	if err != nil || strings.Contains(string(data), "debug-port") {
+		t.Fatalf("expected the hidden argument to be omitted from help JSON, got %s (%v)", data, err)
+	}
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "-dp=9000"})
+	if err != nil || result.Arguments["debug-port"].(int) != 9000 {
+		t.Fatalf("expected the hidden argument to be parsed, got %v", err)
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive"})
+	if err == nil || err.Error() != "missing required argument: `debug-port`" {
+		t.Fatalf("expected the hidden argument to still be required, got %v", err)
+	}
+}
+
+func TestHiddenFlags(t *testing.T) {
+	cli := olive.NewCLI("olive", "", false)
+	cli.AddFlag("verbose", "v", "Verbose output")
+	cli.AddFlag("debug-internals", "D", "Dump internal state").SetHidden()
+
+	help := cli.HelpMessage()
+	if strings.Contains(help, "debug-internals") || strings.Contains(help, "-D") || !strings.Contains(help, "verbose") {
+		t.Fatalf("expected only the hidden flag to be omitted from help:\n%s", help)
+	}
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "--debug-internals"})
+	if err != nil || !result.HasFlag("debug-internals") {
+		t.Fatalf("expected the hidden flag to be parsed, got %v", err)
+	}
+}
+
+func TestIllegalNames(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+
+	err := cli.AddFlags([]olive.FlagDef{
+		{Name: "my flag", ShortName: "m"},
+		{Name: "eq", ShortName: "="},
+		{Name: "--dash", ShortName: "d"},
+		{Name: "ok", ShortName: "o"},
+	})
+
+	expected := "flag name `my flag` cannot contain whitespace\n" +
+		"flag name `=` cannot contain an assignment separator\n" +
+		"flag name `--dash` cannot begin with a dash"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected errors for the illegal names, got %v", err)
+	}
+
+	if !cli.HasFlagDefined("ok") {
+		t.Fatal("expected the legal flag to be defined")
+	}
+
+	sub := olive.NewCLI("olive", "", true)
+	sub.SetAssignmentSeparator(":")
+	err = sub.AddSubcommand("build", "", true).AddArgs([]olive.ArgDef{{Name: "key:value", ShortName: "k"}})
+	if err == nil || err.Error() != "argument name `key:value` cannot contain an assignment separator" {
+		t.Fatalf("expected an error for a name containing the custom separator, got %v", err)
+	}
+}
+
+func TestCaseInsensitiveNames(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	cli.AddFlag("version", "V", "")
+	cli.AddStringArg("outputDir", "o", "", false)
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "--Verbose"}); err == nil {
+		t.Fatal("expected names to be case sensitive by default")
+	}
+
+	cli.CaseInsensitiveNames = true
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "--VERBOSE", "--outputdir=dir", "-V"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !result.HasFlag("verbose") || !result.HasFlag("version") || result.Arguments["outputDir"].(string) != "dir" {
+		t.Fatalf("expected the names to match ignoring case, got %v", result.Arguments)
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-O=dir"}); err == nil {
+		t.Fatal("expected short names to remain case sensitive")
+	}
+
+	cli.CaseInsensitiveShortNames = true
+	if result, err := olive.ParseArgs(cli, []string{"olive", "-O=dir"}); err != nil || result.Arguments["outputDir"].(string) != "dir" {
+		t.Fatalf("expected short names to match ignoring case, got %v", err)
+	}
+
+	if !strings.Contains(cli.HelpMessage(), "--outputDir") {
+		t.Fatalf("expected help to use the registered casing:\n%s", cli.HelpMessage())
+	}
+
+	fatalCount := 0
+	monkey.Patch(log.Fatalf, func(format string, v ...interface{}) {
+		fatalCount++
+	})
+
+	defer monkey.Unpatch(log.Fatalf)
+
+	cli.AddFlag("Verbose", "b", "")
+	cli.AddStringArg("OUTPUTDIR", "d", "", false)
+	if fatalCount != 2 {
+		t.Fatalf("expected names differing only by case to be rejected, got %d errors", fatalCount)
+	}
+}
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelError
+)
+
+func TestChoiceArg(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	level := cli.AddChoiceArg("level", "l", "the log level", false, map[string]interface{}{
+		"debug": levelDebug,
+		"info":  levelInfo,
+		"error": levelError,
+	})
+	level.SetDefaultValue("info")
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "--level=error"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if lvl, ok := result.Arguments["level"].(logLevel); !ok || lvl != levelError {
+		t.Fatalf("expected the typed value of the choice, got %v", result.Arguments["level"])
+	}
+
+	if !reflect.DeepEqual(result.ToArgs(), []string{"--level=error"}) {
+		t.Fatalf("expected the choice to render as its name, got %v", result.ToArgs())
+	}
+
+	result, err = olive.ParseArgs(cli, []string{"olive"})
+	if err != nil || result.Arguments["level"].(logLevel) != levelInfo {
+		t.Fatalf("expected the default choice, got %v", result.Arguments["level"])
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "--level=warn"})
+	if err == nil || err.Error() != "argument 1: `warn` is not a valid value for argument [level] (expected one of debug, error, info)" {
+		t.Fatalf("expected an invalid choice error, got %v", err)
+	}
+
+	if !strings.Contains(cli.HelpMessage(), "--level=<debug|error|info>") {
+		t.Fatalf("expected the choices in the usage line:\n%s", cli.HelpMessage())
+	}
+}
+
+func TestGroupShortFlags(t *testing.T) {
+	cli := olive.NewCLI("olive", "", false)
+	cli.GroupShortFlags = true
+	cli.AddFlag("verbose", "v", "")
+	cli.AddFlag("quiet", "q", "")
+	cli.AddFlag("all", "a", "")
+	cli.AddFlag("no-color", "nc", "")
+	cli.AddStringArg("output", "o", "", false)
+
+	usage := cli.HelpMessage()
+	if !strings.Contains(usage, "[-aqv]") {
+		t.Fatalf("expected the short flags to be grouped, got:\n%s", usage)
+	}
+
+	if !strings.Contains(usage, "[-nc|--no-color]") || !strings.Contains(usage, "[-o|--output=<string>]") {
+		t.Fatalf("expected the remaining flags and arguments to be listed individually, got:\n%s", usage)
+	}
+}
+
+func TestCountableFlags(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "").SetCountable()
+	cli.AddFlag("quiet", "q", "")
+
+	testCases := []struct {
+		args  []string
+		count int
+	}{
+		{[]string{"olive"}, 0},
+		{[]string{"olive", "-v"}, 1},
+		{[]string{"olive", "-v", "--verbose", "-v"}, 3},
+		{[]string{"olive", "--verbose=3"}, 3},
+		{[]string{"olive", "-v=2", "-v"}, 3},
+		{[]string{"olive", "-vvv"}, 3},
+		{[]string{"olive", "-vqv", "--verbose"}, 3},
+	}
+
+	for _, tc := range testCases {
+		result, err := olive.ParseArgs(cli, tc.args)
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %s", tc.args, err.Error())
+		}
+
+		if result.FlagCount("verbose") != tc.count {
+			t.Fatalf("expected a count of %d for %v, got %d", tc.count, tc.args, result.FlagCount("verbose"))
+		}
+	}
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "--verbose=3"})
+	if err != nil || !reflect.DeepEqual(result.ToArgs(), []string{"--verbose=3"}) {
+		t.Fatalf("expected the count to render as a value, got %v", result.ToArgs())
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "--verbose=lots"})
+	if err == nil || err.Error() != "argument 1: count of flag `verbose` must be a positive integer, got `lots`" {
+		t.Fatalf("expected an invalid count error, got %v", err)
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "--quiet=2"}); err == nil {
+		t.Fatal("expected a value for a flag which is not countable to be rejected")
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-vqq"}); err == nil || err.Error() != "argument 1: flag `quiet` set multiple times" {
+		t.Fatalf("expected a flag which is not countable to still be rejected when repeated, got %v", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddIntArg("port", "p", "", false)
+	cli.AddStringArg("host", "", "", false)
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "--port=8080"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if port, ok := olive.Get[int](result, "port"); !ok || port != 8080 {
+		t.Fatalf("expected the port to be 8080, got %d", port)
+	}
+
+	if _, ok := olive.Get[string](result, "port"); ok {
+		t.Fatal("expected a type mismatch to be reported")
+	}
+
+	if host, ok := olive.Get[string](result, "host"); ok || host != "" {
+		t.Fatalf("expected no value for an argument which was not given, got `%s`", host)
+	}
+}
+
+func TestCompletionSpec(t *testing.T) {
+	cli := olive.NewCLI("olive", "", false)
+	cli.AddFlag("verbose", "v", "")
+
+	c := cli.AddSubcommand("build", "", false)
+	c.AddPrimaryArg("package", "", false)
+	c.AddSelectorArg("mode", "m", "", false, []string{"debug", "release"}).SetValueDescriptions(map[string]string{
+		"debug": "Build without optimizations",
+	})
+	c.AddStringArg("log", "l", "", false).SetOptionalValue("out.log")
+	c.AddStringArg("secret", "s", "", false).SetHidden()
+
+	data, err := cli.CompletionSpec()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	var spec map[string]interface{}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("invalid JSON: %s", err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"name":            "olive",
+		"primaryArgument": false,
+		"arguments":       []interface{}{},
+		"flags": []interface{}{
+			map[string]interface{}{"name": "verbose", "shortName": "v"},
+		},
+		"subcommands": []interface{}{
+			map[string]interface{}{
+				"name":            "build",
+				"primaryArgument": true,
+				"arguments": []interface{}{
+					map[string]interface{}{"name": "log", "shortName": "l", "takesValue": true, "optionalValue": true},
+					map[string]interface{}{
+						"name": "mode", "shortName": "m", "takesValue": true, "optionalValue": false,
+						"candidates":            []interface{}{"debug", "release"},
+						"candidateDescriptions": map[string]interface{}{"debug": "Build without optimizations"},
+					},
+				},
+				"flags":       []interface{}{},
+				"subcommands": []interface{}{},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(spec, expected) {
+		t.Fatalf("unexpected completion spec: %s", string(data))
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	olive.DefaultRegistry["timeout"] = "30"
+	defer delete(olive.DefaultRegistry, "timeout")
+
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddIntArg("timeout", "t", "", false)
+
+	c := cli.AddSubcommand("fetch", "", false)
+	c.AddIntArg("timeout", "t", "", false).SetDefaultValue(5)
+	cli.RequiresSubcommand = false
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "fetch"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if result.Arguments["timeout"].(int) != 30 {
+		t.Fatalf("expected the registry default, got %v", result.Arguments["timeout"])
+	}
+
+	_, subres, _ := result.Subcommand()
+	if subres.Arguments["timeout"].(int) != 5 {
+		t.Fatalf("expected the explicit default to take precedence, got %v", subres.Arguments["timeout"])
+	}
+
+	result, err = olive.ParseArgs(cli, []string{"olive", "--timeout=10"})
+	if err != nil || result.Arguments["timeout"].(int) != 10 {
+		t.Fatalf("expected the given value to take precedence, got %v", result.Arguments["timeout"])
+	}
+
+	// an invalid registry value is reported as an error rather than exiting
+	olive.DefaultRegistry["timeout"] = "soon"
+	_, err = olive.ParseArgs(cli, []string{"olive"})
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid default for argument `timeout` in DefaultRegistry: ") {
+		t.Fatalf("expected an invalid registry default error, got %v", err)
+	}
+}
+
+func TestRelationshipGroups(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("json", "j", "")
+	cli.AddFlag("yaml", "y", "")
+	cli.AddStringArg("user", "u", "", false)
+	cli.AddStringArg("password", "p", "", false).SetDefaultValue("")
+	cli.MarkMutuallyExclusive("json", "yaml")
+	cli.MarkRequiredTogether("user", "password")
+
+	testCases := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"olive", "--json", "--user=me", "--password=pw"}, ""},
+		{[]string{"olive"}, ""},
+		{[]string{"olive", "--json", "--yaml"}, "`--json` and `--yaml` cannot be given together"},
+		{[]string{"olive", "--user=me"}, "`--user` must be given together with `--password`"},
+	}
+
+	for _, tc := range testCases {
+		_, err := olive.ParseArgs(cli, tc.args)
+		if tc.err == "" && err != nil {
+			t.Fatalf("unexpected error for %v: %s", tc.args, err.Error())
+		} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Fatalf("expected error `%s` for %v, got %v", tc.err, tc.args, err)
+		}
+	}
+
+	if errs := cli.Lint(); len(errs) != 0 {
+		t.Fatalf("unexpected lint errors: %v", errs)
+	}
+
+	cli.MarkMutuallyExclusive("json", "xml")
+	errs := cli.Lint()
+	if len(errs) != 1 || errs[0].Error() != "group of command `olive` references unknown flag or argument `xml`" {
+		t.Fatalf("expected a lint error for the unknown name, got %v", errs)
+	}
+}
+
+func TestWriteHelpTo(t *testing.T) {
+	cli := olive.NewCLI("olive", "Olive CLI", false)
+	cli.AddFlag("verbose", "v", "Verbose output")
+
+	var buff strings.Builder
+	if err := cli.WriteHelpTo(&buff); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if buff.String() != cli.HelpMessage() {
+		t.Fatalf("expected the written help to match the help message, got:\n%s", buff.String())
+	}
+}
+
+func TestSingleDash(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddPrimaryArg("file", "", false)
+	cli.AddStringArg("input", "i", "", false)
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "-"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if file, ok := result.PrimaryArg(); !ok || file != "-" {
+		t.Fatalf("expected `-` to be the primary argument, got `%s`", file)
+	}
+
+	result, err = olive.ParseArgs(cli, []string{"olive", "--input", "-"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if result.Arguments["input"].(string) != "-" {
+		t.Fatalf("expected `-` to be the value of the argument, got `%v`", result.Arguments["input"])
+	}
+}
+
+func TestStringCodec(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	payload := cli.AddStringArg("payload", "p", "", false)
+	payload.SetValidator(func(s string) error {
+		if !strings.HasPrefix(s, "{") {
+			return errors.New("expected an object")
+		}
+
+		return nil
+	})
+	payload.SetDefaultValue(`{"n": 1}`)
+	payload.SetCodec(func(s string) (interface{}, error) {
+		var v map[string]interface{}
+		err := json.Unmarshal([]byte(s), &v)
+		return v, err
+	})
+
+	result, err := olive.ParseArgs(cli, []string{"olive", `--payload={"n": 2}`})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(result.Arguments["payload"], map[string]interface{}{"n": 2.0}) {
+		t.Fatalf("expected the decoded value, got %v", result.Arguments["payload"])
+	}
+
+	result, err = olive.ParseArgs(cli, []string{"olive"})
+	if err != nil || !reflect.DeepEqual(result.Arguments["payload"], map[string]interface{}{"n": 1.0}) {
+		t.Fatalf("expected the decoded default value, got %v", result.Arguments["payload"])
+	}
+
+	if _, err = olive.ParseArgs(cli, []string{"olive", "--payload=[]"}); err == nil || !strings.Contains(err.Error(), "expected an object") {
+		t.Fatalf("expected the validator to run before decoding, got %v", err)
+	}
+
+	if _, err = olive.ParseArgs(cli, []string{"olive", "--payload={"}); err == nil || !strings.Contains(err.Error(), "unable to decode value `{` of argument [payload]") {
+		t.Fatalf("expected a decoding error, got %v", err)
+	}
+}
+
+func TestWithoutProgramName(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	cli.AddStringArg("output", "o", "", false)
+
+	result, err := olive.ParseArgs(cli, []string{"-v", "--output=out"}, olive.WithoutProgramName())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !result.HasFlag("verbose") || result.Arguments["output"].(string) != "out" {
+		t.Fatalf("expected every argument to be parsed, got %v", result.Arguments)
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"-v", "--bogus"}, olive.WithoutProgramName())
+	if err == nil || err.Error() != "argument 1: unknown flag: `bogus`" {
+		t.Fatalf("expected the position of the token in the arguments given, got %v", err)
+	}
+}
+
+func TestEffective(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddIntArg("jobs", "j", "", false).SetDefaultValue(4)
+
+	build := cli.AddSubcommand("build", "", true)
+	build.AddStringArg("output", "o", "", false)
+	build.AddSubcommand("docs", "", false).AddStringArg("format", "f", "", false).SetDefaultValue("html")
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "build", "docs", "--output=out"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"jobs":              4,
+		"build.output":      "out",
+		"build.docs.format": "html",
+	}
+
+	if !reflect.DeepEqual(result.Effective(), expected) {
+		t.Fatalf("expected %v, got %v", expected, result.Effective())
+	}
+}
+
+func TestParseKnownArgs(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	build := cli.AddSubcommand("build", "", false)
+	build.AddStringArg("output", "o", "", false)
+
+	result, leftover, err := olive.ParseKnownArgs(cli, []string{"olive", "--plugin", "build", "-v", "--output=out", "--mode=fast", "extra"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(leftover, []string{"--plugin", "--mode=fast", "extra"}) {
+		t.Fatalf("expected the unrecognized tokens to be left over, got %v", leftover)
+	}
+
+	name, subres, ok := result.Subcommand()
+	if !ok || name != "build" || !subres.HasFlagRecursive("verbose") || subres.Arguments["output"].(string) != "out" {
+		t.Fatalf("expected the recognized tokens to be parsed, got %v", result)
+	}
+
+	if _, _, err := olive.ParseKnownArgs(cli, []string{"olive", "build", "--output"}); err == nil {
+		t.Fatal("expected errors other than unrecognized tokens to be reported")
+	}
+}
+
+func TestBoolArg(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddBoolArg("enabled", "e", "", false).SetDefaultValue(true)
+
+	testCases := []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{"olive"}, true},
+		{[]string{"olive", "--enabled=false"}, false},
+		{[]string{"olive", "-e=1"}, true},
+		{[]string{"olive", "-e=0"}, false},
+		{[]string{"olive", "--enabled=yes"}, true},
+		{[]string{"olive", "--enabled=No"}, false},
+	}
+
+	for _, tc := range testCases {
+		result, err := olive.ParseArgs(cli, tc.args)
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %s", tc.args, err.Error())
+		}
+
+		if result.Arguments["enabled"].(bool) != tc.expected {
+			t.Fatalf("expected %v for %v, got %v", tc.expected, tc.args, result.Arguments["enabled"])
+		}
+	}
+
+	_, err := olive.ParseArgs(cli, []string{"olive", "--enabled=maybe"})
+	if err == nil || err.Error() != "argument 1: argument \"enabled\" value \"maybe\" must be a boolean" {
+		t.Fatalf("expected an invalid boolean error, got %v", err)
+	}
+
+	if !strings.Contains(cli.HelpMessage(), "--enabled=<bool>") {
+		t.Fatalf("expected the value type in the usage line:\n%s", cli.HelpMessage())
+	}
+}
+
+func TestSpaceSeparatedValues(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.RequiresSubcommand = false
+	cli.AddStringArg("output", "o", "", false)
+	cli.AddIntArg("offset", "", "", false)
+	cli.AddSubcommand("build", "", false)
+
+	testCases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"olive", "--output", "out"}, "out"},
+		{[]string{"olive", "-o", "out"}, "out"},
+		{[]string{"olive", "--output=out"}, "out"},
+		{[]string{"olive", "--output", "build"}, "build"},
+		{[]string{"olive", "--output=-v"}, "-v"},
+		{[]string{"olive", "-o", "-"}, "-"},
+		{[]string{"olive", "--output="}, ""},
+		{[]string{"olive", "-o="}, ""},
+	}
+
+	for _, tc := range testCases {
+		result, err := olive.ParseArgs(cli, tc.args)
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %s", tc.args, err.Error())
+		}
+
+		if result.Arguments["output"].(string) != tc.expected {
+			t.Fatalf("expected `%s` for %v, got `%v`", tc.expected, tc.args, result.Arguments["output"])
+		}
+
+		if _, _, ok := result.Subcommand(); ok {
+			t.Fatalf("expected the value not to be treated as a subcommand for %v", tc.args)
+		}
+	}
+
+	// an explicitly empty value does not wait for the next token
+	result, err := olive.ParseArgs(cli, []string{"olive", "--output=", "build"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if result.Arguments["output"].(string) != "" {
+		t.Fatalf("expected an empty value, got `%v`", result.Arguments["output"])
+	}
+
+	if name, _, ok := result.Subcommand(); !ok || name != "build" {
+		t.Fatal("expected the token after an empty value to be the subcommand")
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "--offset", "-5"})
+	if err == nil || err.Error() != "argument 2: missing value for argument `offset` (values starting with `-` must be given as `--offset=-5`)" {
+		t.Fatalf("expected a missing value error, got %v", err)
+	}
+}
+
+func TestCombinedShortFlags(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	cli.AddFlag("force", "f", "")
+	cli.AddFlag("all", "a", "")
+	cli.AddFlag("no-cache", "nc", "")
+	cli.AddFlag("no", "n", "")
+	cli.AddFlag("color", "c", "")
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "-vf", "-nc"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !result.HasFlag("verbose") || !result.HasFlag("force") || result.HasFlag("all") {
+		t.Fatal("expected exactly the combined flags to be set")
+	}
+
+	if !result.HasFlag("no-cache") || result.HasFlag("no") || result.HasFlag("color") {
+		t.Fatal("expected a registered short name to take precedence over combined flags")
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-vx"}); err == nil || err.Error() != "argument 1: unknown flag by short name: `vx`" {
+		t.Fatalf("expected an unknown flag error, got %v", err)
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-vv"}); err == nil || err.Error() != "argument 1: flag `verbose` set multiple times" {
+		t.Fatalf("expected a duplicate flag error, got %v", err)
+	}
+}
+
+func TestTerminatorRawTokens(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.ExitFunc = func(int) { t.Fatal("expected the help flag after the terminator to be ignored") }
+
+	exec := cli.AddSubcommand("exec", "", true)
+	exec.AddFlag("verbose", "v", "")
+	exec.AddFlag("force", "f", "")
+	exec.AddStringArg("output", "o", "", false)
+
+	args := []string{"./script", "--not-my-flag", "-h", "-vf", "--output=x", "-o", "exec", "-"}
+	result, err := olive.ParseArgs(cli, append([]string{"olive", "exec", "--"}, args...))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	_, res, _ := result.Subcommand()
+	if !reflect.DeepEqual(res.TrailingArgs(), args) {
+		t.Fatalf("expected the tokens to be collected verbatim, got %v", res.TrailingArgs())
+	}
+
+	if res.HasFlag("verbose") || res.HasFlag("force") || len(res.Arguments) != 0 {
+		t.Fatal("expected no flags or arguments to be set after the terminator")
+	}
+}
+
+func TestNegatableFlags(t *testing.T) {
+	cli := olive.NewCLI("olive", "", false)
+	cli.AddFlag("color", "c", "Colorize output").SetNegatable()
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "--no-color"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !result.FlagNegated("color") || result.HasFlag("color") {
+		t.Fatal("expected the flag to be negated")
+	}
+
+	if !reflect.DeepEqual(result.ToArgs(), []string{"--no-color"}) {
+		t.Fatalf("expected the negation to be rendered, got %v", result.ToArgs())
+	}
+
+	result, err = olive.ParseArgs(cli, []string{"olive", "-c"})
+	if err != nil || result.FlagNegated("color") || !result.HasFlag("color") {
+		t.Fatalf("expected the flag to be set, got %v", err)
+	}
+
+	for _, args := range [][]string{{"olive", "--color", "--no-color"}, {"olive", "--no-color", "-c"}} {
+		var te *olive.TokenError
+		if _, err := olive.ParseArgs(cli, args); !errors.As(err, &te) || te.Kind != olive.KindConflict {
+			t.Fatalf("expected a conflict error for %v, got %v", args, err)
+		}
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "--no-colour"}); err == nil {
+		t.Fatal("expected an unknown negation to be rejected")
+	}
+
+	if !strings.Contains(cli.HelpMessage(), "--[no-]color") {
+		t.Fatalf("expected the negation in help:\n%s", cli.HelpMessage())
+	}
+}
+
+func TestRepeatedStringList(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	include := cli.AddStringListArg("include", "I", "", false)
+	include.SetDefaultValue([]string{"/usr/include"})
+	include.SetValidator(func(s string) error {
+		if !strings.HasPrefix(s, "/") {
+			return errors.New("must be an absolute path")
+		}
+
+		return nil
+	})
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "-I=/a", "-I", "/b,/c", "--include=/d"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(result.Arguments["include"], []string{"/a", "/b", "/c", "/d"}) {
+		t.Fatalf("expected the values to be collected in order, got %v", result.Arguments["include"])
+	}
+
+	result, err = olive.ParseArgs(cli, []string{"olive"})
+	if err != nil || !reflect.DeepEqual(result.Arguments["include"], []string{"/usr/include"}) {
+		t.Fatalf("expected the default value, got %v", result.Arguments["include"])
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-I=/a", "-I=b"}); err == nil || !strings.Contains(err.Error(), "must be an absolute path") {
+		t.Fatalf("expected every element to be validated, got %v", err)
+	}
+}
+
+func TestIntListArg(t *testing.T) {
+	cli := olive.NewCLI("olive", "", false)
+	port := cli.AddIntListArg("port", "p", "", false)
+	port.SetValidator(func(n int) error {
+		if n > 65535 {
+			return errors.New("not a valid port")
+		}
+
+		return nil
+	})
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "--port=80", "-p", "443"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(result.Arguments["port"], []int{80, 443}) {
+		t.Fatalf("expected the values to be collected in order, got %v", result.Arguments["port"])
+	}
+
+	if !reflect.DeepEqual(result.ToArgs(), []string{"--port=80", "--port=443"}) {
+		t.Fatalf("expected the argument to be repeated, got %v", result.ToArgs())
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "--port=80", "--port=http"}); err == nil || !strings.Contains(err.Error(), "must be an integer") {
+		t.Fatalf("expected an invalid integer error, got %v", err)
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "--port=80", "--port=80000"}); err == nil || !strings.Contains(err.Error(), "not a valid port") {
+		t.Fatalf("expected every element to be validated, got %v", err)
+	}
+
+	if !strings.Contains(cli.HelpMessage(), "--port=<int>...") {
+		t.Fatalf("expected repetition to be hinted in the usage line:\n%s", cli.HelpMessage())
+	}
+}
+
+func TestGetSlices(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddStringListArg("include", "I", "", false)
+	cli.AddIntListArg("port", "p", "", false)
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "-I=/a", "-I=/b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if elems, ok := result.GetStringSlice("include"); !ok || !reflect.DeepEqual(elems, []string{"/a", "/b"}) {
+		t.Fatalf("expected the string list, got %v", elems)
+	}
+
+	if elems, ok := result.GetIntSlice("port"); ok || elems == nil || len(elems) != 0 {
+		t.Fatalf("expected an empty slice for an absent argument, got %v", elems)
+	}
+
+	if elems, ok := result.GetIntSlice("include"); ok || len(elems) != 0 {
+		t.Fatalf("expected a type mismatch to be reported, got %v", elems)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	var printed []interface{}
+	monkey.Patch(fmt.Println, func(a ...interface{}) (int, error) {
+		printed = append(printed, a...)
+		return 0, nil
+	})
+
+	defer monkey.Unpatch(fmt.Println)
+
+	exitCode := -1
+	cli := olive.NewCLI("olive", "", true)
+	cli.ExitFunc = func(code int) { exitCode = code }
+	cli.SetVersion("1.2.0")
+	cli.AddVersionCommand()
+	cli.AddSubcommand("build", "", false)
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "build", "-V"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !result.HasFlag("version") || exitCode != 0 || !reflect.DeepEqual(printed, []interface{}{"1.2.0"}) {
+		t.Fatalf("expected the version to be displayed before exiting, got %v (exit code %d)", printed, exitCode)
+	}
+
+	args := os.Args
+	os.Args = []string{"olive", "version"}
+	defer func() { os.Args = args }()
+
+	printed, exitCode = nil, -1
+	cli.Execute()
+
+	if exitCode != 0 || !reflect.DeepEqual(printed, []interface{}{"1.2.0"}) {
+		t.Fatalf("expected the version command to display the version, got %v (exit code %d)", printed, exitCode)
+	}
+}
+
+func TestGenerateBashCompletion(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+
+	build := cli.AddSubcommand("build", "", true)
+	build.AddStringArg("output", "o", "", false)
+	build.AddStringArg("secret", "s", "", false).SetHidden()
+	build.AddSubcommand("docs", "", false)
+
+	script := olive.GenerateBashCompletion(cli)
+
+	expected := []string{
+		"_olive_completions() {",
+		"            'olive:build') cmd='olive build' ;;",
+		"            'olive build:docs') cmd='olive build docs' ;;",
+		"        'olive') words='build --help --verbose' ;;",
+		"        'olive build') words='docs --help --verbose --output' ;;",
+		"        'olive build docs') words='--help --verbose --output' ;;",
+		"complete -F _olive_completions olive",
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(script, line+"\n") {
+			t.Fatalf("expected the line `%s` in the script:\n%s", line, script)
+		}
+	}
+
+	if strings.Contains(script, "secret") {
+		t.Fatalf("expected hidden arguments to be omitted:\n%s", script)
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	t.Setenv("OLIVE_DB_URL", "postgres://env")
+	t.Setenv("OLIVE_JOBS", "many")
+
+	cli := olive.NewCLI("olive", "", true)
+	dbURL := cli.AddStringArg("db-url", "d", "", false)
+	dbURL.SetEnvVar("OLIVE_DB_URL")
+	dbURL.SetDefaultValue("postgres://default")
+
+	timeout := cli.AddIntArg("timeout", "t", "", false)
+	timeout.SetEnvVar("OLIVE_UNSET_TIMEOUT")
+	timeout.SetDefaultValue(30)
+
+	result, err := olive.ParseArgs(cli, []string{"olive"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if result.Arguments["db-url"].(string) != "postgres://env" || result.Arguments["timeout"].(int) != 30 {
+		t.Fatalf("expected the environment to take precedence over defaults, got %v", result.Arguments)
+	}
+
+	result, err = olive.ParseArgs(cli, []string{"olive", "--db-url=postgres://cli"})
+	if err != nil || result.Arguments["db-url"].(string) != "postgres://cli" {
+		t.Fatalf("expected the given value to take precedence over the environment, got %v", result.Arguments)
+	}
+
+	cli.AddIntArg("jobs", "j", "", false).SetEnvVar("OLIVE_JOBS")
+	if _, err := olive.ParseArgs(cli, []string{"olive"}); err == nil || !strings.HasPrefix(err.Error(), "invalid value in environment variable `OLIVE_JOBS`: ") {
+		t.Fatalf("expected an invalid environment value error, got %v", err)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.RequiresSubcommand = false
+	cli.AddIntArg("jobs", "j", "", false)
+	cli.AddBoolArg("cache", "c", "", false)
+	cli.AddSelectorArg("mode", "m", "", false, []string{"debug", "release"})
+
+	build := cli.AddSubcommand("build", "", false)
+	build.AddStringListArg("include", "I", "", false)
+
+	// like `SetDefaultValue`, loading a default path does not check it
+	cache := build.AddPathArg("cache-dir", "cd", "", false)
+	cache.MustExist()
+
+	path := filepath.Join(t.TempDir(), "defaults.json")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"jobs": 4, "cache": false, "mode": "release", "build": {"include": ["/a", "/b"], "cache-dir": "does-not-exist"}}`)
+	if err := olive.LoadDefaults(cli, path); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	result, err := olive.ParseArgs(cli, []string{"olive", "build", "--jobs=2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if result.Arguments["jobs"].(int) != 2 || result.Arguments["cache"].(bool) || result.Arguments["mode"].(string) != "release" {
+		t.Fatalf("expected the loaded defaults, got %v", result.Arguments)
+	}
+
+	if _, subres, _ := result.Subcommand(); !reflect.DeepEqual(subres.Arguments["include"], []string{"/a", "/b"}) {
+		t.Fatalf("expected the loaded defaults of the subcommand, got %v", subres.Arguments)
+	}
+
+	if def, ok := cache.GetDefaultValue(); !ok || !filepath.IsAbs(def.(string)) || filepath.Base(def.(string)) != "does-not-exist" {
+		t.Fatalf("expected the loaded default path to be absolute, got %v", def)
+	}
+
+	testCases := []struct {
+		content, err string
+	}{
+		{`{"bogus": 1}`, "unknown argument: `bogus`"},
+		{`{"jobs": "4"}`, "invalid default for argument `jobs`: expected a number, got a string"},
+		{`{"jobs": 4.5}`, "invalid default for argument `jobs`: argument \"jobs\" value \"4.5\" must be an integer"},
+		{`{"mode": "fast"}`, "invalid default for argument `mode`: `fast` is not a valid value for argument [mode]"},
+		{`{"build": {"include": ["/a", 1]}}`, "invalid default for argument `build.include`: expected a string for every element, got a number"},
+	}
+
+	for _, tc := range testCases {
+		write(tc.content)
+		if err := olive.LoadDefaults(cli, path); err == nil || err.Error() != "invalid defaults file `"+path+"`: "+tc.err {
+			t.Fatalf("expected the error `%s` for %s, got %v", tc.err, tc.content, err)
+		}
+	}
+}
+
+func TestRequiredOneOf(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("json", "j", "")
+	cli.AddFlag("yaml", "y", "")
+	cli.AddRequiredOneOf("json", "yaml")
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "--yaml"}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive"}); err == nil || err.Error() != "one of --json, --yaml is required" {
+		t.Fatalf("expected a missing group error, got %v", err)
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-j", "-y"}); err == nil || err.Error() != "`--json` and `--yaml` cannot be given together" {
+		t.Fatalf("expected a conflict error, got %v", err)
+	}
+}
+
+func TestIntRange(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	level := cli.AddIntArg("level", "l", "", false)
+	level.SetRange(0, 100)
+	level.SetValidator(func(v int) error {
+		if v%10 != 0 {
+			return errors.New("must be a multiple of 10")
+		}
+
+		return nil
+	})
+
+	for _, val := range []string{"0", "50", "100"} {
+		if _, err := olive.ParseArgs(cli, []string{"olive", "--level=" + val}); err != nil {
+			t.Fatalf("unexpected error for `%s`: %s", val, err.Error())
+		}
+	}
+
+	_, err := olive.ParseArgs(cli, []string{"olive", "--level=200"})
+	if err == nil || !strings.Contains(err.Error(), "value 200 out of range [0, 100]") {
+		t.Fatalf("expected a range error, got %v", err)
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "--level=-1"})
+	if err == nil || !strings.Contains(err.Error(), "value -1 out of range [0, 100]") {
+		t.Fatalf("expected a range error, got %v", err)
+	}
+
+	if _, err = olive.ParseArgs(cli, []string{"olive", "--level=55"}); err == nil {
+		t.Fatal("expected the validator to reject the value")
+	}
+
+	fatalCalled := false
+	monkey.Patch(log.Fatalf, func(format string, v ...interface{}) {
+		fatalCalled = true
+	})
+
+	defer monkey.Unpatch(log.Fatalf)
+
+	level.SetDefaultValue(120)
+	if !fatalCalled {
+		t.Fatal("default value out of range should be rejected")
+	}
+
+	fatalCalled = false
+	jobs := cli.AddIntArg("jobs", "j", "", false)
+	jobs.SetDefaultValue(0)
+	jobs.SetRange(1, 8)
+	if !fatalCalled {
+		t.Fatal("default value set before the range should be checked against it")
+	}
+}
+
+func TestFloatRange(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	ratio := cli.AddFloatArg("ratio", "r", "", false)
+	ratio.SetRange(0, 1.5)
+
+	for _, val := range []string{"0", "0.75", "1.5"} {
+		if _, err := olive.ParseArgs(cli, []string{"olive", "--ratio=" + val}); err != nil {
+			t.Fatalf("unexpected error for `%s`: %s", val, err.Error())
+		}
+	}
+
+	for val, msg := range map[string]string{
+		"1.75": "value 1.75 out of range [0, 1.5]",
+		"-0.5": "value -0.5 out of range [0, 1.5]",
+		"NaN":  "value NaN out of range [0, 1.5]",
+	} {
+		_, err := olive.ParseArgs(cli, []string{"olive", "--ratio=" + val})
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("expected a range error for `%s`, got %v", val, err)
+		}
+	}
+
+	fatalCalled := false
+	monkey.Patch(log.Fatalf, func(format string, v ...interface{}) {
+		fatalCalled = true
+	})
+
+	defer monkey.Unpatch(log.Fatalf)
+
+	ratio.SetDefaultValue(2)
+	if !fatalCalled {
+		t.Fatal("default value out of range should be rejected")
+	}
+
+	fatalCalled = false
+	scale := cli.AddFloatArg("scale", "s", "", false)
+	scale.SetDefaultValue(0.5)
+	scale.SetRange(1, 2)
+	if !fatalCalled {
+		t.Fatal("default value set before the range should be checked against it")
+	}
+}
+
+func TestPathArg(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cli := olive.NewCLI("olive", "", true)
+	input := cli.AddPathArg("input", "i", "", false)
+	input.MustBeFile()
+	out := cli.AddPathArg("out", "o", "", false)
+	out.MustBeDir()
+	cli.AddPathArg("log", "l", "", false)
+
+	res, err := olive.ParseArgs(cli, []string{"olive", "--input=" + dir + "/./input.txt", "--out=" + dir, "--log=log.txt"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if v := res.Arguments["input"]; v != file {
+		t.Fatalf("expected the cleaned path `%s`, got `%v`", file, v)
+	}
+
+	wd, _ := os.Getwd()
+	if v := res.Arguments["log"]; v != filepath.Join(wd, "log.txt") {
+		t.Fatalf("expected an absolute path, got `%v`", v)
+	}
+
+	for args, msg := range map[string]string{
+		"--input=missing.txt": "path 'missing.txt' does not exist",
+		"--input=" + dir:      "path '" + dir + "' is not a file",
+		"--out=" + file:       "path '" + file + "' is not a directory",
+	} {
+		_, err := olive.ParseArgs(cli, []string{"olive", args})
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("expected error containing `%s`, got %v", msg, err)
+		}
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	cli.AddFlag("verbose", "v", "")
+	cli.AddStringArg("name", "n", "", true)
+	cli.AddIntArg("jobs", "j", "", false).SetValidator(func(v int) error {
+		if v < 1 {
+			return errors.New("must be positive")
+		}
+
+		return nil
+	})
+
+	_, err := olive.ParseArgs(cli, []string{"olive", "--verbos"})
+	var ufe *olive.UnknownFlagError
+	if !errors.As(err, &ufe) || ufe.Name != "verbos" || ufe.ShortName {
+		t.Fatalf("expected an unknown flag error, got %v", err)
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "-x=1"})
+	var uae *olive.UnknownArgError
+	if !errors.As(err, &uae) || uae.Name != "x" || !uae.ShortName {
+		t.Fatalf("expected an unknown argument error, got %v", err)
+	} else if !strings.HasSuffix(err.Error(), "unknown argument by short name: `x`") {
+		t.Fatalf("unexpected message: %s", err.Error())
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "--name=a", "--jobs=0"})
+	var ve *olive.ValidationError
+	if !errors.As(err, &ve) || ve.Arg != "jobs" || ve.Value != "0" || ve.Err.Error() != "must be positive" {
+		t.Fatalf("expected a validation error, got %v", err)
+	} else if !strings.HasSuffix(err.Error(), "argument \"jobs\" rejected value \"0\": must be positive") {
+		t.Fatalf("unexpected message: %s", err.Error())
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive"})
+	var mre *olive.MissingRequiredError
+	if !errors.As(err, &mre) || mre.Name != "name" || err.Error() != "missing required argument: `name`" {
+		t.Fatalf("expected a missing required error, got %v", err)
+	}
+
+	cli = olive.NewCLI("olive", "", true)
+	cli.AddIntArg("jobs", "j", "", false).SetRange(1, 8)
+	cli.AddSelectorArg("mode", "m", "", false, []string{"debug", "release"})
+	cli.AddPathArg("config", "c", "", false).MustExist()
+
+	build := cli.AddSubcommand("build", "", true)
+	build.AddPrimaryArg("package", "", true)
+	cli.AddSubcommand("mod", "", true).AddSubcommand("init", "", true)
+
+	var ive *olive.InvalidValueError
+	for _, arg := range []string{"--jobs=x", "--mode=fast"} {
+		_, err = olive.ParseArgs(cli, []string{"olive", arg, "build", "pkg"})
+		if !errors.As(err, &ive) {
+			t.Fatalf("expected an invalid value error for `%s`, got %v", arg, err)
+		}
+	}
+
+	if ive.Arg != "mode" || ive.Value != "fast" || err.Error() != "argument 1: `fast` is not a valid value for argument [mode]" {
+		t.Fatalf("unexpected invalid value error: %v", err)
+	}
+
+	var re *olive.RangeError
+	for _, arg := range []string{"--jobs=9", "--jobs=99999999999999999999"} {
+		_, err = olive.ParseArgs(cli, []string{"olive", arg, "build", "pkg"})
+		if !errors.As(err, &re) || re.Arg != "jobs" {
+			t.Fatalf("expected a range error for `%s`, got %v", arg, err)
+		}
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "--config=does-not-exist", "build", "pkg"})
+	var pe *olive.PathError
+	if !errors.As(err, &pe) || pe.Arg != "config" || pe.Value != "does-not-exist" {
+		t.Fatalf("expected a path error, got %v", err)
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "mod"})
+	var mse *olive.MissingSubcommandError
+	if !errors.As(err, &mse) || mse.Command != "mod" || err.Error() != "`mod` requires a subcommand" {
+		t.Fatalf("expected a missing subcommand error, got %v", err)
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "build"})
+	var mpe *olive.MissingPrimaryArgError
+	if !errors.As(err, &mpe) || mpe.Command != "build" || mpe.Arg != "package" || err.Error() != "command \"build\" requires argument <package>" {
+		t.Fatalf("expected a missing primary argument error, got %v", err)
+	}
+}
+
+func TestHelpDefaults(t *testing.T) {
+	cli := olive.NewCLI("olive", "", false)
+	cli.AddIntArg("jobs", "j", "Number of jobs", false).SetDefaultValue(4)
+	cli.AddFloatArg("ratio", "r", "Compression ratio", false).SetDefaultValue(0.5)
+	cli.AddStringArg("output", "o", "Output directory", false).SetDefaultValue("out")
+	cli.AddSelectorArg("mode", "m", "Build mode", false, []string{"debug", "release"}).SetDefaultValue("release")
+	cli.AddStringArg("name", "n", "Project name", true)
+
+	help := cli.HelpMessage()
+	for _, expected := range []string{
+		"Number of jobs (default: 4)",
+		"Compression ratio (default: 0.5)",
+		"Output directory (default: out)",
+		"Build mode (default: release)",
+	} {
+		if !strings.Contains(help, expected) {
+			t.Fatalf("expected `%s` in help:\n%s", expected, help)
+		}
+	}
+
+	if strings.Contains(help, "Project name (default") {
+		t.Fatalf("expected no default for the required argument:\n%s", help)
+	}
+}
+
+func TestRun(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+
+	var ran string
+	mod := cli.AddSubcommand("mod", "", true)
+	mod.SetHandler(func(context.Context, *olive.ArgParseResult) error {
+		ran = "mod"
+		return nil
+	})
+
+	modInit := mod.AddSubcommand("init", "", true)
+	modInit.AddStringArg("name", "n", "", false)
+	modInit.SetHandler(func(_ context.Context, res *olive.ArgParseResult) error {
+		ran = "mod init " + res.Arguments["name"].(string)
+		return nil
+	})
+
+	mod.RequiresSubcommand = false
+
+	if err := cli.Run([]string{"olive", "mod", "init", "--name=app"}); err != nil || ran != "mod init app" {
+		t.Fatalf("expected the most specific handler to run, got %q (%v)", ran, err)
+	}
+
+	if err := cli.Run([]string{"olive", "mod"}); err != nil || ran != "mod" {
+		t.Fatalf("expected the subcommand handler to run, got %q (%v)", ran, err)
+	}
+
+	handlerErr := errors.New("handler failed")
+	modInit.SetHandler(func(context.Context, *olive.ArgParseResult) error { return handlerErr })
+	if err := cli.Run([]string{"olive", "mod", "init"}); err != handlerErr {
+		t.Fatalf("expected the handler error to propagate, got %v", err)
+	}
+
+	var te *olive.TokenError
+	if err := cli.Run([]string{"olive", "bogus"}); !errors.As(err, &te) {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	type key struct{}
+
+	cli := olive.NewCLI("olive", "", true)
+	serve := cli.AddSubcommand("serve", "", true)
+
+	var got interface{}
+	serve.SetHandler(func(ctx context.Context, _ *olive.ArgParseResult) error {
+		got = ctx.Value(key{})
+		return ctx.Err()
+	})
+
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	if err := cli.RunContext(ctx, []string{"olive", "serve"}); err != nil || got != "value" {
+		t.Fatalf("expected the context to be passed to the handler, got %v (%v)", got, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	got = nil
+	if err := cli.RunContext(cancelled, []string{"olive", "serve"}); !errors.Is(err, context.Canceled) || got != nil {
+		t.Fatalf("expected the handler not to run with a cancelled context, got %v", err)
+	}
+
+	if err := cli.Run([]string{"olive", "serve"}); err != nil || got != nil {
+		t.Fatalf("expected a background context, got %v (%v)", got, err)
+	}
+}
+
+func TestVariadicPrimaryArg(t *testing.T) {
+	cli := olive.NewCLI("rm", "", false)
+	cli.AddFlag("force", "f", "")
+	cli.AddVariadicPrimaryArg("files", "The files to remove")
+
+	res, err := olive.ParseArgs(cli, []string{"rm", "a.txt", "-f", "b.txt", "--", "-c.txt"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if files := res.PrimaryArgs(); !reflect.DeepEqual(files, []string{"a.txt", "b.txt", "-c.txt"}) {
+		t.Fatalf("expected all the positional values, got %v", files)
+	}
+
+	if first, ok := res.PrimaryArg(); !ok || first != "a.txt" {
+		t.Fatalf("expected the first value as the primary argument, got `%s`", first)
+	}
+
+	if args := res.ToArgs(); !reflect.DeepEqual(args, []string{"--force", "--", "a.txt", "b.txt", "-c.txt"}) {
+		t.Fatalf("unexpected round trip arguments: %v", args)
+	}
+
+	res, err = olive.ParseArgs(cli, []string{"rm"})
+	if err != nil || res.PrimaryArgs() != nil {
+		t.Fatalf("expected no values, got %v (%v)", res.PrimaryArgs(), err)
+	}
+
+	if !strings.Contains(cli.HelpMessage(), "[files...]") {
+		t.Fatalf("expected the variadic argument in the usage line:\n%s", cli.HelpMessage())
+	}
+
+	single := olive.NewCLI("olive", "", false)
+	single.AddPrimaryArg("file", "", false)
+	res, err = olive.ParseArgs(single, []string{"olive", "a.txt"})
+	if err != nil || !reflect.DeepEqual(res.PrimaryArgs(), []string{"a.txt"}) {
+		t.Fatalf("expected the single primary argument, got %v (%v)", res.PrimaryArgs(), err)
+	}
+
+	if _, err := olive.ParseArgs(single, []string{"olive", "a.txt", "b.txt"}); err == nil {
+		t.Fatal("expected an error for multiple values of a single primary argument")
+	}
+
+	fatalCalled := false
+	monkey.Patch(log.Fatalf, func(format string, v ...interface{}) {
+		fatalCalled = true
+	})
+
+	defer monkey.Unpatch(log.Fatalf)
+
+	cli.AddSubcommand("sub", "", false)
+	if !fatalCalled {
+		t.Fatal("a variadic primary argument should be exclusive with subcommands")
+	}
+}
+
+func TestInterspersedFlags(t *testing.T) {
+	cli := olive.NewCLI("olive", "", false)
+	cli.AddFlag("verbose", "v", "")
+
+	mod := cli.AddSubcommand("mod", "", false)
+	mod.AddIntArg("jobs", "j", "", false)
+	mod.AddSubcommand("init", "", false).AddPrimaryArg("name", "", false)
+
+	build := cli.AddSubcommand("build", "", false)
+	build.AddFlag("debug", "d", "")
+	build.AddPrimaryArg("package", "", false)
+
+	res, err := olive.ParseArgs(cli, []string{"olive", "-v", "build", "--debug", "pkg"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	name, subres, _ := res.Subcommand()
+	if !res.HasFlag("verbose") || name != "build" || !subres.HasFlag("debug") {
+		t.Fatal("expected the global flag before the subcommand and the flag after it")
+	} else if pkg, _ := subres.PrimaryArg(); pkg != "pkg" {
+		t.Fatalf("expected primary argument `pkg`, got `%s`", pkg)
+	}
+
+	res, err = olive.ParseArgs(cli, []string{"olive", "mod", "-j=2", "-v", "init", "app"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	_, modRes, _ := res.Subcommand()
+	_, initRes, ok := modRes.Subcommand()
+	if !ok || !res.HasFlag("verbose") || modRes.Arguments["jobs"] != 2 {
+		t.Fatal("expected flags between subcommands to be accepted")
+	} else if name, _ := initRes.PrimaryArg(); name != "app" {
+		t.Fatalf("expected primary argument `app`, got `%s`", name)
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "build", "-d", "pkg", "mod"}); err == nil {
+		t.Fatal("expected an error for a subcommand after a primary argument")
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "-v", "bogus"}); err == nil || !strings.Contains(err.Error(), "unknown subcommand: `bogus`") {
+		t.Fatalf("expected an unknown subcommand error, got %v", err)
+	}
+}
+
+func TestCaseInsensitiveSelector(t *testing.T) {
+	cli := olive.NewCLI("olive", "", true)
+	level := cli.AddSelectorArg("log-level", "l", "", false, []string{"debug", "Info", "INFO", "warn"})
+	level.SetCaseInsensitive()
+
+	for val, expected := range map[string]string{"INFO": "Info", "info": "Info", "Debug": "debug", "WaRn": "warn"} {
+		res, err := olive.ParseArgs(cli, []string{"olive", "--log-level=" + val})
+		if err != nil {
+			t.Fatalf("unexpected error for `%s`: %s", val, err.Error())
+		}
+
+		if res.Arguments["log-level"] != expected {
+			t.Fatalf("expected `%s` for `%s`, got `%v`", expected, val, res.Arguments["log-level"])
+		}
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "--log-level=trace"}); err == nil {
+		t.Fatal("expected an error for an invalid value")
+	}
+
+	level.SetDefaultValue("WARN")
+	if def, _ := level.GetDefaultValue(); def != "warn" {
+		t.Fatalf("expected the canonical default value, got `%v`", def)
+	}
+}
+
+func TestCaseInsensitiveSubcommands(t *testing.T) {
+	cli := olive.NewCLI("olive", "", false)
+	cli.CaseInsensitiveNames = true
+	cli.CaseInsensitiveShortNames = true
+	cli.AddFlag("verbose", "v", "")
+
+	build := cli.AddSubcommand("build", "", false)
+	build.AddFlag("vendor", "V", "")
+	build.AddStringArg("output", "o", "", false)
+	cli.RequiresSubcommand = false
+
+	res, err := olive.ParseArgs(cli, []string{"olive", "--VERBOSE", "BUILD", "-O=out"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	name, subres, ok := res.Subcommand()
+	if !ok || name != "build" || subres.Arguments["output"] != "out" {
+		t.Fatalf("expected the subcommand and its argument to match ignoring case, got `%s`", name)
+	} else if !res.HasFlag("verbose") {
+		t.Fatal("expected the flag to match ignoring case")
+	}
+
+	// exact matches anywhere on the stack take precedence over folded matches
+	res, err = olive.ParseArgs(cli, []string{"olive", "build", "-v", "-V"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	_, subres, _ = res.Subcommand()
+	if !res.HasFlag("verbose") || !subres.HasFlag("vendor") {
+		t.Fatal("expected `-v` to match the root flag and `-V` the subcommand flag")
+	}
+
+	fatalCount := 0
+	monkey.Patch(log.Fatalf, func(format string, v ...interface{}) {
+		fatalCount++
+	})
+
+	defer monkey.Unpatch(log.Fatalf)
+
+	cli.AddSubcommand("Build", "", false)
+	if fatalCount != 1 {
+		t.Fatalf("expected subcommand names differing only by case to be rejected, got %d errors", fatalCount)
+	}
+}
+
+func TestPrefixMatch(t *testing.T) {
+	cli := olive.NewCLI("olive", "", false)
+	cli.AddFlag("verbose", "v", "")
+	cli.AddFlag("version", "V", "")
+	cli.AddFlag("force", "f", "")
+	cli.AddFlag("debug-internals", "D", "").SetHidden()
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "--verb"}); err == nil {
+		t.Fatal("expected prefixes not to match by default")
+	}
+
+	cli.AllowPrefixMatch = true
+
+	res, err := olive.ParseArgs(cli, []string{"olive", "--verb", "--fo"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	} else if !res.HasFlag("verbose") || !res.HasFlag("force") || res.HasFlag("version") {
+		t.Fatal("expected the unambiguous prefixes to match their flags")
+	}
+
+	_, err = olive.ParseArgs(cli, []string{"olive", "--ver"})
+	if err == nil || !strings.HasSuffix(err.Error(), "ambiguous flag '--ver' matches --verbose, --version") {
+		t.Fatalf("expected an ambiguous flag error, got %v", err)
+	}
+
+	if _, err := olive.ParseArgs(cli, []string{"olive", "--debug"}); err == nil {
+		t.Fatal("expected hidden flags not to match by prefix")
+	}
+}
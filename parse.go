@@ -1,8 +1,14 @@
 package olive
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // argParser is a state machine used to parse arguments
@@ -27,24 +33,135 @@ type argParser struct {
 	// corresponding command at any depth within the parsing stack
 	semanticStack []*ArgParseResult
 
-	// allowSubcommands indicates whether or not a flag or argument has already
-	// been encountered and therefore subcommands are no longer valid
-	allowSubcommands bool
+	// collectingTrailing indicates that all remaining arguments should be
+	// collected as trailing arguments of the current command
+	collectingTrailing bool
+
+	// terminated indicates that the `--` terminator has been encountered and
+	// all remaining arguments are literal values of the current command
+	terminated bool
+
+	// lenient indicates that the parser should never exit the application.
+	// Instead, any action that would exit halts parsing.
+	lenient bool
+
+	// halted indicates that an action has stopped parsing in lenient mode
+	halted bool
+
+	// pendingArg is a named argument given without a value whose value is the
+	// next argument token.  pendingNdx is the position of the command the
+	// argument belongs to on the command stack.
+	pendingArg Argument
+	pendingNdx int
+
+	// base is the result whose flags and argument values are used for any
+	// flags and arguments which are not given.  It may be `nil`.
+	base *ArgParseResult
+
+	// pool is the parser pool that results are drawn from.  If it is `nil`, a
+	// new result is allocated for every command.
+	pool *ParserPool
+
+	// knownOnly indicates that tokens which are not recognized should be
+	// collected as leftover tokens instead of failing parsing
+	knownOnly bool
+	leftover  []string
+
+	// withoutProgramName indicates that the arguments do not begin with the
+	// application name
+	withoutProgramName bool
+
+	// offset is the position of the first token being parsed in the arguments
+	// given which is used to report the positions of tokens in errors
+	offset int
+
+	// invokedName is the name the application was invoked with if it is used
+	// in help and usage messages.  It is kept on the parser and its result
+	// rather than the initial command so that parsing never modifies the CLI.
+	invokedName string
+}
+
+// ParseOption is an option which changes how arguments are parsed by
+// `ParseArgs`
+type ParseOption func(*argParser)
+
+// WithoutProgramName indicates that the arguments given to `ParseArgs` do not
+// begin with the application name so none of them should be skipped
+func WithoutProgramName() ParseOption {
+	return func(ap *argParser) {
+		ap.withoutProgramName = true
+	}
+}
+
+// parseArgs parses a full set of arguments including the application name
+func (ap *argParser) parseArgs(args []string) (*ArgParseResult, error) {
+	ap.invokedName = ""
+
+	if ap.withoutProgramName {
+		ap.offset = 0
+		return ap.parse(args)
+	}
+
+	if ap.initialCommand.UseInvokedName && len(args) > 0 {
+		ap.invokedName = filepath.Base(args[0])
+	}
+
+	// trim off the first argument which is conventionally the application name
+	ap.offset = 1
+	return ap.parse(args[1:])
 }
 
 // parse runs the main parsing algorithm on a set of argument values
 func (ap *argParser) parse(args []string) (*ArgParseResult, error) {
-	ap.result = &ArgParseResult{
-		flags:     make(map[string]struct{}),
-		Arguments: make(map[string]interface{}),
+	ap.result = ap.newResult()
+	ap.result.command = ap.initialCommand
+	ap.result.invokedName = ap.invokedName
+	ap.commandStack = append(ap.commandStack[:0], ap.initialCommand)
+	ap.semanticStack = append(ap.semanticStack[:0], ap.result)
+	ap.collectingTrailing = false
+	ap.terminated = false
+	ap.halted = false
+	ap.pendingArg = nil
+
+	c := ap.initialCommand
+	if len(args) == 0 && c.HelpOnNoArgs && c.RequiresSubcommand && len(c.subcommands) > 0 {
+		fmt.Println(getHelpMessage(c, ap.result))
+		ap.exit(0)
+
+		// the result is empty if exiting does not stop the application
+		return ap.result, nil
 	}
-	ap.commandStack = []*Command{ap.initialCommand}
-	ap.semanticStack = []*ArgParseResult{ap.result}
-	ap.allowSubcommands = true
 
-	for _, arg := range args {
+	for i, arg := range args {
 		if err := ap.consume(arg); err != nil {
-			return nil, err
+			// unrecognized tokens are left for another parser without affecting
+			// the state of this parser
+			if ap.knownOnly && isUnrecognized(err) {
+				ap.leftover = append(ap.leftover, arg)
+				continue
+			}
+
+			// priority flags still run their actions despite the error
+			if ap.runPriorityFlags(args[i+1:]); ap.halted {
+				return ap.result, nil
+			}
+
+			return nil, newTokenError(i+ap.offset, arg, err)
+		}
+
+		// an action has requested an exit in lenient mode: the result is
+		// returned as is without any further checks
+		if ap.halted {
+			return ap.result, nil
+		}
+	}
+
+	if ap.pendingArg != nil {
+		return nil, &TokenError{
+			Index: len(args) - 1 + ap.offset,
+			Token: args[len(args)-1],
+			Kind:  KindMissingValue,
+			Err:   fmt.Errorf("missing value for argument `%s`", ap.pendingArg.Name()),
 		}
 	}
 
@@ -55,7 +172,7 @@ func (ap *argParser) parse(args []string) (*ArgParseResult, error) {
 	// next item).  We only check this field if there are subcommands to be
 	// missing
 	if len(ap.currCommand().subcommands) > 0 && ap.currCommand().RequiresSubcommand {
-		return nil, fmt.Errorf("`%s` requires a subcommand", ap.currCommand().Name)
+		return nil, &MissingSubcommandError{Command: ap.currCommand().Name}
 	}
 
 	// since only the last command in the chain can have primary arguments
@@ -63,134 +180,783 @@ func (ap *argParser) parse(args []string) (*ArgParseResult, error) {
 	// we only have to check to see if the last command is missing a required
 	// primary argument
 	if ap.currCommand().primaryArg != nil && ap.currCommand().primaryArg.required && ap.currResult().primaryArg == "" {
-		return nil, fmt.Errorf("missing required primary argument `%s` for subcommand `%s`", ap.currCommand().Name, ap.currCommand().primaryArg.name)
+		return nil, &MissingPrimaryArgError{Command: ap.currCommand().Name, Arg: ap.currCommand().primaryArg.name}
 	}
 
-	// set all the default values of any unsupplied arguments; go in reverse
-	// order so most specific subcommand gets precedence
-	for i := len(ap.commandStack) - 1; i > -1; i-- {
-		for _, arg := range ap.commandStack[i].args {
+	if err := ap.checkDisableFlags(); err != nil {
+		return nil, err
+	}
+
+	if err := ap.checkGroups(); err != nil {
+		return nil, err
+	}
+
+	if ap.base != nil {
+		ap.applyBase()
+	}
+
+	if err := ap.fillDefaults(); err != nil {
+		return nil, err
+	}
+
+	// if the CLI allows it, prompt for any required arguments which are still
+	// missing a value now that the defaults have been filled in
+	if ap.initialCommand.PromptForMissing {
+		if err := ap.promptForMissing(); err != nil {
+			return nil, err
+		}
+	}
+
+	// only the commands on the stack are checked so the required arguments of
+	// subcommands which were not entered are never reported
+	ap.result.missingRequired = ap.missingRequired()
+	if len(ap.result.missingRequired) > 0 {
+		missing := ap.result.missingRequired
+		return nil, &MissingRequiredError{Name: missing[0], Missing: missing}
+	}
+
+	for i, c := range ap.commandStack {
+		for _, bind := range c.bindings {
+			bind(ap.semanticStack[i])
+		}
+	}
+
+	return ap.result, nil
+}
+
+// DefaultRegistry is the raw default values of arguments by name used for
+// arguments which have no default value of their own (eg. `"timeout": "30"`).
+// The values are converted like values given on the command line.  It should
+// only be modified before parsing begins.
+var DefaultRegistry = map[string]string{}
+
+// fillDefaults sets the values of any unsupplied arguments from their
+// environment variables or their default values.  The commands are visited
+// from the initial command down so that the values of ancestor commands are
+// resolved before they are inherited.
+func (ap *argParser) fillDefaults() error {
+	for i, c := range ap.commandStack {
+		for _, arg := range c.args {
+			if !arg.Enabled() {
+				continue
+			}
+
+			if _, ok := ap.semanticStack[i].Arguments[arg.Name()]; ok || ap.disabledByFlag(i, arg) {
+				continue
+			}
+
+			if envVar := arg.base().envVar; envVar != "" {
+				if raw, ok := os.LookupEnv(envVar); ok {
+					val, err := arg.checkValue(raw)
+					if err != nil {
+						return fmt.Errorf("invalid value in environment variable `%s`: %w", envVar, err)
+					}
+
+					ap.storeValue(i, arg, raw, val)
+					continue
+				}
+			}
+
+			if arg.base().inheritDefault {
+				if val, raw, ok := ap.inheritedValue(i, arg.Name()); ok {
+					ap.storeValue(i, arg, raw, val)
+					continue
+				}
+			}
+
 			if val, ok := arg.GetDefaultValue(); ok {
-				if _, ok := ap.semanticStack[i].Arguments[arg.Name()]; !ok {
-					ap.semanticStack[i].Arguments[arg.Name()] = val
+				if !arg.base().defaultDisplayOnly {
+					ap.storeValue(i, arg, rawDefault(arg, val), val)
+				}
+			} else if raw, ok := DefaultRegistry[arg.Name()]; ok {
+				val, err := arg.checkValue(raw)
+				if err != nil {
+					return fmt.Errorf("invalid default for argument `%s` in DefaultRegistry: %w", arg.Name(), err)
 				}
+
+				ap.storeValue(i, arg, raw, val)
 			}
 		}
 	}
 
-	return ap.result, nil
+	return nil
+}
+
+// applyBase copies the flags and argument values of the base result into the
+// results of the commands on the stack wherever they were not given.  Only the
+// flags and arguments defined by each command are copied.  The
+// results of subcommands are matched by name.  Values explicitly given always
+// take precedence: base values of arguments disabled by a given disable flag
+// and base disable flags of given arguments are ignored.
+func (ap *argParser) applyBase() {
+	base := ap.base
+	for i, c := range ap.commandStack {
+		if i > 0 {
+			if base.subcommandName != c.Name {
+				return
+			}
+
+			base = base.subcommandRes
+		}
+
+		res := ap.semanticStack[i]
+		for name, val := range base.Arguments {
+			arg, ok := c.args[name]
+			if !ok || !arg.Enabled() || ap.disabledByFlag(i, arg) {
+				continue
+			}
+
+			if _, given := res.Arguments[name]; given {
+				continue
+			}
+
+			res.Arguments[name] = val
+
+			if raw, ok := base.rawValues[name]; ok {
+				if res.rawValues == nil {
+					res.rawValues = make(map[string]string)
+				}
+
+				res.rawValues[name] = raw
+			}
+		}
+
+		for name := range base.flags {
+			if _, ok := c.flags[name]; !ok || ap.disablesGivenArg(i, name) {
+				continue
+			}
+
+			if _, given := res.flags[name]; given || res.FlagNegated(name) {
+				continue
+			}
+
+			res.flags[name] = struct{}{}
+
+			if count, ok := base.counts[name]; ok {
+				if res.counts == nil {
+					res.counts = make(map[string]int)
+				}
+
+				res.counts[name] = count
+			}
+		}
+
+		for name := range base.negated {
+			if _, ok := c.flags[name]; !ok {
+				continue
+			}
+
+			if _, given := res.flags[name]; given || res.FlagNegated(name) {
+				continue
+			}
+
+			if res.negated == nil {
+				res.negated = make(map[string]struct{})
+			}
+
+			res.negated[name] = struct{}{}
+		}
+	}
+}
+
+// disablesGivenArg checks whether a flag is the disable flag of an argument of
+// the command at the given position on the stack which has a value
+func (ap *argParser) disablesGivenArg(ndx int, flagName string) bool {
+	for _, arg := range ap.commandStack[ndx].args {
+		if df := arg.base().disableFlag; df != nil && df.name == flagName {
+			_, ok := ap.semanticStack[ndx].Arguments[arg.Name()]
+			return ok
+		}
+	}
+
+	return false
+}
+
+// inheritedValue finds the value of the closest ancestor's argument with the
+// given name for the command at the given position on the stack.  It also
+// returns the value as it was given.
+func (ap *argParser) inheritedValue(ndx int, name string) (interface{}, string, bool) {
+	for i := ndx - 1; i > -1; i-- {
+		res := ap.semanticStack[i]
+		if val, ok := res.Arguments[name]; ok {
+			if raw, ok := res.rawValues[name]; ok {
+				return val, raw, true
+			}
+
+			return val, fmt.Sprint(val), true
+		}
+	}
+
+	return nil, "", false
+}
+
+// rawDefault returns the default value of an argument as it was given
+func rawDefault(arg Argument, val interface{}) string {
+	if sa, ok := arg.(*StringArgument); ok && sa.codec != nil {
+		return sa.rawDefault
+	}
+
+	return fmt.Sprint(val)
+}
+
+// checkDisableFlags checks that no argument was given along with its disable
+// flag
+func (ap *argParser) checkDisableFlags() error {
+	for i, c := range ap.commandStack {
+		for _, arg := range c.Arguments() {
+			if _, ok := ap.semanticStack[i].Arguments[arg.Name()]; ok && ap.disabledByFlag(i, arg) {
+				return fmt.Errorf("argument `%s` cannot be given together with `--%s`", arg.Name(), arg.base().disableFlag.name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// disabledByFlag checks whether the disable flag of an argument of the command
+// at the given position on the stack was given
+func (ap *argParser) disabledByFlag(ndx int, arg Argument) bool {
+	if df := arg.base().disableFlag; df != nil {
+		_, ok := ap.semanticStack[ndx].flags[df.name]
+		return ok
+	}
+
+	return false
+}
+
+// isMissing checks whether an argument of the command at the given position on
+// the stack is required but has not received a value
+func (ap *argParser) isMissing(ndx int, arg Argument) bool {
+	if !arg.Required() || !arg.Enabled() || ap.disabledByFlag(ndx, arg) {
+		return false
+	}
+
+	_, ok := ap.semanticStack[ndx].Arguments[arg.Name()]
+	return !ok
+}
+
+// runPriorityFlags runs the action of the first priority flag among the given
+// remaining argument tokens.  It stops at the `--` terminator.
+func (ap *argParser) runPriorityFlags(args []string) {
+	if ap.collectingTrailing || ap.terminated {
+		return
+	}
+
+	for _, arg := range args {
+		if arg == "--" {
+			return
+		}
+
+		if !strings.HasPrefix(arg, "-") || strings.Contains(arg, ap.initialCommand.assignSep) {
+			continue
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		if ndx, flag, ok := ap.lookupFlag(name, !strings.HasPrefix(arg, "--")); ok && flag.priority {
+			ap.setFlag(ndx, flag)
+			return
+		}
+	}
+}
+
+// missingRequired returns the qualified names of all enabled, required
+// arguments on the command stack which have not received a value
+func (ap *argParser) missingRequired() []string {
+	var missing []string
+
+	for i, c := range ap.commandStack {
+		names := make([]string, 0, len(c.args))
+		for name, arg := range c.args {
+			if ap.isMissing(i, arg) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			missing = append(missing, ap.qualifiedName(i, name))
+		}
+	}
+
+	return missing
+}
+
+// qualifiedName returns the name of an argument qualified by the path of
+// subcommands leading to the command at the given position on the stack
+func (ap *argParser) qualifiedName(ndx int, name string) string {
+	var b strings.Builder
+	for _, c := range ap.commandStack[1 : ndx+1] {
+		b.WriteString(c.Name)
+		b.WriteRune('.')
+	}
+
+	b.WriteString(name)
+	return b.String()
+}
+
+// isUnrecognized checks whether an error produced while consuming a token was
+// caused by the token not being recognized by the CLI
+func isUnrecognized(err error) bool {
+	var ke *kindError
+	if !errors.As(err, &ke) {
+		return false
+	}
+
+	switch ke.kind {
+	case KindUnknownFlag, KindUnknownArgument, KindUnknownSubcommand, KindUnexpectedSubcommand:
+		return true
+	}
+
+	return false
 }
 
 // consume processes a single argument token of input
 func (ap *argParser) consume(arg string) error {
-	if strings.HasPrefix(arg, "--") {
-		ap.allowSubcommands = false
+	if ap.pendingArg != nil {
+		return ap.consumePendingValue(arg)
+	}
 
+	if ap.collectingTrailing {
+		ap.currResult().trailingArgs = append(ap.currResult().trailingArgs, arg)
+		return nil
+	}
+
+	if ap.terminated {
+		// the first literal value is still the primary argument if the command
+		// has not received one yet
+		if pa := ap.currCommand().primaryArg; pa != nil && (pa.variadic || ap.currResult().primaryArg == "") {
+			ap.setPrimaryArg(arg)
+		} else {
+			ap.currResult().trailingArgs = append(ap.currResult().trailingArgs, arg)
+		}
+
+		return nil
+	}
+
+	if arg == "--" {
+		// everything after the terminator belongs to the current command
+		ap.terminated = true
+		return nil
+	}
+
+	if strings.HasPrefix(arg, "--") {
 		// handle full-named arguments
-		argName, argVal := ap.extractComponents(arg)
+		argName, argVal, hasVal := ap.extractComponents(arg)
 
-		if argVal == "" {
+		if !hasVal {
 			// => flag
-			for i := len(ap.commandStack) - 1; i > -1; i-- {
-				if flag, ok := ap.commandStack[i].flags[argName]; ok {
-					if err := ap.setFlag(i, flag); err != nil {
-						return err
-					} else {
-						return nil
-					}
+			if ndx, flag, ok := ap.lookupFlag(argName, false); ok {
+				return ap.setFlag(ndx, flag)
+			}
+
+			// => argument whose value is implied or the next token
+			if ndx, arg, ok := ap.lookupArg(argName, false); ok {
+				return ap.setValuelessArg(ndx, arg)
+			}
+
+			// => negated flag
+			if name := strings.TrimPrefix(argName, "no-"); name != argName {
+				if ndx, flag, ok := ap.lookupFlag(name, false); ok && flag.negatable {
+					return ap.negateFlag(ndx, flag)
 				}
 			}
 
-			return fmt.Errorf("unknown flag: `%s`", argName)
+			// => unambiguous prefix of a flag
+			if ap.initialCommand.AllowPrefixMatch {
+				if ndx, flag, err := ap.lookupFlagPrefix(argName); err != nil {
+					return err
+				} else if flag != nil {
+					return ap.setFlag(ndx, flag)
+				}
+			}
+
+			// the name may be a misspelled flag or a misspelled argument whose
+			// value is the next token: the error reports whichever is closest
+			if suggestion, isArg := ap.suggestName(argName, true); isArg {
+				return kindErrorOf(KindUnknownArgument, &UnknownArgError{Name: argName, Suggestion: suggestion})
+			} else {
+				return kindErrorOf(KindUnknownFlag, &UnknownFlagError{Name: argName, Suggestion: suggestion})
+			}
 		} else {
+			// => countable flag with an explicit count
+			if ndx, flag, ok := ap.lookupFlag(argName, false); ok && flag.countable {
+				return ap.setFlagCount(ndx, flag, argVal)
+			}
+
 			// => argument
-			for i := len(ap.commandStack) - 1; i > -1; i-- {
-				if arg, ok := ap.commandStack[i].args[argName]; ok {
-					if err := ap.setArg(i, arg, argVal); err != nil {
-						return err
-					} else {
-						return nil
-					}
-				}
+			if ndx, arg, ok := ap.lookupArg(argName, false); ok {
+				return ap.setArg(ndx, arg, argVal)
 			}
 
-			return fmt.Errorf("unknown argument: `%s`", argName)
+			suggestion, _ := ap.suggestName(argName, false)
+			return kindErrorOf(KindUnknownArgument, &UnknownArgError{Name: argName, Suggestion: suggestion})
 		}
-	} else if strings.HasPrefix(arg, "-") {
-		ap.allowSubcommands = false
+	} else if strings.HasPrefix(arg, "-") && arg != "-" {
+		// handle short-named arguments: a bare `-` is instead handled as a
+		// literal value below (conventionally standard input)
+		argName, argVal, hasVal := ap.extractComponents(arg)
 
-		// handle short-named arguments
-		argName, argVal := ap.extractComponents(arg)
-
-		if argVal == "" {
+		if !hasVal {
 			// => flag
-			for i := len(ap.commandStack) - 1; i > -1; i-- {
-				if flag, ok := ap.commandStack[i].flagsByShortName[argName]; ok {
-					if err := ap.setFlag(i, flag); err != nil {
-						return err
-					} else {
-						return nil
-					}
-				}
+			if ndx, flag, ok := ap.lookupFlag(argName, true); ok {
+				return ap.setFlag(ndx, flag)
+			}
+
+			// => argument whose value is implied or the next token
+			if ndx, arg, ok := ap.lookupArg(argName, true); ok {
+				return ap.setValuelessArg(ndx, arg)
 			}
 
-			return fmt.Errorf("unknown flag by short name: `%s`", argName)
+			// => combined single character flags (eg. `-vfh`)
+			if ok, err := ap.setCombinedFlags(argName); ok {
+				return err
+			}
+
+			return kindErrorOf(KindUnknownFlag, &UnknownFlagError{Name: argName, ShortName: true})
 		} else {
+			// => countable flag with an explicit count
+			if ndx, flag, ok := ap.lookupFlag(argName, true); ok && flag.countable {
+				return ap.setFlagCount(ndx, flag, argVal)
+			}
+
 			// => argument
-			for i := len(ap.commandStack) - 1; i > -1; i-- {
-				if arg, ok := ap.commandStack[i].argsByShortName[argName]; ok {
-					if err := ap.setArg(i, arg, argVal); err != nil {
-						return err
-					} else {
-						return nil
-					}
-				}
+			if ndx, arg, ok := ap.lookupArg(argName, true); ok {
+				return ap.setArg(ndx, arg, argVal)
 			}
 
-			return fmt.Errorf("unknown argument by short name: `%s`", argName)
+			return kindErrorOf(KindUnknownArgument, &UnknownArgError{Name: argName, ShortName: true})
 		}
 	} else if ap.currCommand().primaryArg != nil {
-		ap.allowSubcommands = false
-
 		// handle primary arguments
-		if ap.currResult().primaryArg != "" {
-			return fmt.Errorf("multiple primary arguments specified for command `%s`", ap.currCommand().Name)
+		if ap.currResult().primaryArg != "" && !ap.currCommand().primaryArg.variadic {
+			return kindErrorf(KindDuplicate, "multiple primary arguments specified for command `%s`", ap.currCommand().Name)
 		}
 
-		ap.currResult().primaryArg = arg
-	} else if ap.allowSubcommands {
-		if subc, ok := ap.currCommand().subcommands[arg]; ok {
+		ap.setPrimaryArg(arg)
+
+		// everything after the primary argument is passed through verbatim if
+		// the command collects trailing arguments: a variadic primary argument
+		// collects them itself
+		ap.collectingTrailing = ap.currCommand().CollectTrailingArgs && !ap.currCommand().primaryArg.variadic
+	} else if len(ap.currCommand().subcommands) > 0 {
+		// flags and arguments may be given before, between and after
+		// subcommands since they are looked up through the whole command stack
+		if subc, ok := ap.lookupSubcommand(arg); ok && subc.available(ap.currResult()) {
 			// handle subcommands
 			ap.commandStack = append(ap.commandStack, subc)
 
-			newResult := &ArgParseResult{
-				Arguments: make(map[string]interface{}),
-				flags:     make(map[string]struct{}),
-			}
+			newResult := ap.newResult()
+			newResult.command = subc
+			newResult.parent = ap.currResult()
 
 			ap.currResult().subcommandRes = newResult
 			ap.currResult().subcommandName = subc.Name
 			ap.semanticStack = append(ap.semanticStack, newResult)
 		} else {
-			return fmt.Errorf("unknown subcommand: `%s`", arg)
+			return kindErrorf(KindUnknownSubcommand, "unknown subcommand: `%s`", arg)
 		}
 	} else {
-		return fmt.Errorf("unexpected subcommand: `%s`", arg)
+		// a command without subcommands or a primary argument takes no
+		// positional values at all
+		return kindErrorf(KindUnexpectedSubcommand, "unexpected subcommand: `%s`", arg)
 	}
 
 	return nil
 }
 
-// extractComponents converts an input string into its two parts: argument name
-// and argument value.  If this input string is setting a flag, then the
-// argument value returned is "".
-func (ap *argParser) extractComponents(arg string) (string, string) {
-	if strings.Contains(arg, "=") {
-		argComponents := strings.Split(arg, "=")
+// setPrimaryArg stores a value of the primary argument of the current command
+func (ap *argParser) setPrimaryArg(val string) {
+	res := ap.currResult()
+	if res.primaryArg == "" {
+		res.primaryArg = val
+	}
+
+	if ap.currCommand().primaryArg.variadic {
+		res.primaryArgs = append(res.primaryArgs, val)
+	}
+}
+
+// setValuelessArg handles a named argument given without a value.  If the
+// value of the argument is optional, it receives its implied value.  Otherwise,
+// the next argument token is its value.
+func (ap *argParser) setValuelessArg(ndx int, arg Argument) error {
+	if arg.base().hasImpliedValue {
+		return ap.setArg(ndx, arg, arg.base().impliedValue)
+	}
+
+	ap.pendingArg, ap.pendingNdx = arg, ndx
+	return nil
+}
+
+// consumePendingValue consumes an argument token as the value of the pending
+// argument.  Tokens beginning with a `-` are never consumed as values: the value
+// is treated as missing instead.
+func (ap *argParser) consumePendingValue(val string) error {
+	arg := ap.pendingArg
+	ap.pendingArg = nil
+
+	// the next token is never swallowed if it looks like a flag or argument.  A
+	// bare `-` is a value like any other (eg. `--input -`).
+	if strings.HasPrefix(val, "-") && val != "-" {
+		return kindErrorf(
+			KindMissingValue,
+			"missing value for argument `%s` (values starting with `-` must be given as `--%s%s%s`)",
+			arg.Name(), arg.Name(), ap.initialCommand.assignSep, val,
+		)
+	}
+
+	return ap.setArg(ap.pendingNdx, arg, val)
+}
+
+// lookupFlag finds an enabled flag by its name or short name searching from the
+// top of the command stack down.  It returns the position of the command the
+// flag belongs to on the command stack.  If names are matched ignoring case,
+// the whole stack is searched for an exact match first.
+func (ap *argParser) lookupFlag(name string, byShortName bool) (int, *Flag, bool) {
+	for _, fold := range ap.matchPasses(byShortName) {
+		for i := len(ap.commandStack) - 1; i > -1; i-- {
+			flags := ap.commandStack[i].flags
+			if byShortName {
+				flags = ap.commandStack[i].flagsByShortName
+			}
+
+			flag, ok := flags[name]
+			if fold {
+				for key, f := range flags {
+					if strings.EqualFold(key, name) && f.Enabled() {
+						flag, ok = f, true
+						break
+					}
+				}
+			}
+
+			if ok && flag.Enabled() {
+				return i, flag, true
+			}
+		}
+	}
+
+	return -1, nil, false
+}
+
+// lookupArg finds an enabled argument by its name or short name searching from
+// the top of the command stack down.  It returns the position of the command
+// the argument belongs to on the command stack.  If names are matched ignoring
+// case, the whole stack is searched for an exact match first.
+func (ap *argParser) lookupArg(name string, byShortName bool) (int, Argument, bool) {
+	for _, fold := range ap.matchPasses(byShortName) {
+		for i := len(ap.commandStack) - 1; i > -1; i-- {
+			args := ap.commandStack[i].args
+			if byShortName {
+				args = ap.commandStack[i].argsByShortName
+			}
+
+			arg, ok := args[name]
+			if fold {
+				for key, a := range args {
+					if strings.EqualFold(key, name) && a.Enabled() {
+						arg, ok = a, true
+						break
+					}
+				}
+			}
 
-		return strings.TrimLeft(argComponents[0], "-"), strings.Join(argComponents[1:], "=")
+			if ok && arg.Enabled() {
+				return i, arg, true
+			}
+		}
+	}
+
+	return -1, nil, false
+}
+
+// matchPasses returns whether each pass of a lookup of a name or short name
+// ignores case: names are always matched exactly before they are matched
+// ignoring case
+func (ap *argParser) matchPasses(byShortName bool) []bool {
+	if ap.ignoreCase(byShortName) {
+		return []bool{false, true}
+	}
+
+	return []bool{false}
+}
+
+// lookupFlagPrefix finds the single enabled flag on the command stack whose
+// name begins with the given prefix.  It returns a `nil` flag if no flag matches
+// and an error if several flags match.
+func (ap *argParser) lookupFlagPrefix(prefix string) (int, *Flag, error) {
+	ignoreCase := ap.ignoreCase(false)
+	foldedPrefix := prefix
+	if ignoreCase {
+		foldedPrefix = strings.ToLower(prefix)
+	}
+
+	var matches []string
+	var matchFlag *Flag
+	matchNdx := -1
+
+	// flags of subcommands shadow those of their parents with the same name
+	seen := make(map[string]struct{})
+	for i := len(ap.commandStack) - 1; i > -1; i-- {
+		for name, flag := range ap.commandStack[i].flags {
+			if _, ok := seen[name]; ok || !flag.Enabled() || flag.hidden {
+				continue
+			}
+
+			seen[name] = struct{}{}
+
+			key := name
+			if ignoreCase {
+				key = strings.ToLower(name)
+			}
+
+			if strings.HasPrefix(key, foldedPrefix) {
+				matches = append(matches, name)
+				matchNdx, matchFlag = i, flag
+			}
+		}
+	}
+
+	if len(matches) > 1 {
+		sort.Strings(matches)
+		return -1, nil, kindErrorf(KindUnknownFlag, "ambiguous flag '--%s' matches --%s", prefix, strings.Join(matches, ", --"))
+	}
+
+	return matchNdx, matchFlag, nil
+}
+
+// lookupSubcommand finds a subcommand of the current command by its name
+func (ap *argParser) lookupSubcommand(name string) (*Command, bool) {
+	subcommands := ap.currCommand().subcommands
+	if subc, ok := subcommands[name]; ok {
+		return subc, true
+	}
+
+	if ap.initialCommand.CaseInsensitiveNames {
+		for subName, subc := range subcommands {
+			if strings.EqualFold(subName, name) {
+				return subc, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// ignoreCase checks whether names or short names are looked up ignoring case
+func (ap *argParser) ignoreCase(byShortName bool) bool {
+	if byShortName {
+		return ap.initialCommand.CaseInsensitiveShortNames
+	}
+
+	return ap.initialCommand.CaseInsensitiveNames
+}
+
+// suggestName finds the name of an enabled argument or, if `withFlags` is set,
+// flag on the command stack that is closest to an unknown name if the CLI allows
+// name suggestions.  Names are only suggested if they are within a small edit
+// distance of the unknown name.  It also returns whether the name suggested is
+// the name of an argument.
+func (ap *argParser) suggestName(name string, withFlags bool) (string, bool) {
+	if !ap.initialCommand.SuggestNames {
+		return "", false
+	}
+
+	type candidate struct {
+		name  string
+		isArg bool
+	}
+
+	var candidates []candidate
+	// hidden flags and arguments are never revealed by suggestions
+	for _, c := range ap.commandStack {
+		for argName, arg := range c.args {
+			if arg.Enabled() && !arg.base().hidden {
+				candidates = append(candidates, candidate{argName, true})
+			}
+		}
+
+		if withFlags {
+			for flagName, flag := range c.flags {
+				if flag.Enabled() && !flag.hidden {
+					candidates = append(candidates, candidate{flagName, false})
+				}
+			}
+		}
+	}
+
+	// sort the candidates so that ties are broken consistently: flags come
+	// before arguments with the same name
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].name == candidates[j].name {
+			return !candidates[i].isArg && candidates[j].isArg
+		}
+
+		return candidates[i].name < candidates[j].name
+	})
+
+	maxDist := 2
+	if len(name) <= 3 {
+		maxDist = 1
+	}
+
+	best, bestDist := candidate{}, maxDist+1
+	for _, cand := range candidates {
+		if dist := editDistance(name, cand.name); dist < bestDist {
+			best, bestDist = cand, dist
+		}
+	}
+
+	return best.name, best.isArg
+}
+
+// editDistance computes the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(br)]
+}
+
+// extractComponents converts an input string into its two parts: argument name
+// and argument value.  The first assignment separator is always the boundary
+// between the name and the value: any further separators are part of the value
+// (eg. `--filter=a=b` has the value `a=b`).  It also returns whether the input
+// string contains a separator at all: if it does not, the input string is
+// setting a flag or an argument whose value is implied or the next token.  An
+// explicitly empty value (eg. `--name=`) is still a value.
+func (ap *argParser) extractComponents(arg string) (string, string, bool) {
+	if argComponents := strings.SplitN(arg, ap.initialCommand.assignSep, 2); len(argComponents) == 2 {
+		return strings.TrimLeft(argComponents[0], "-"), argComponents[1], true
 	} else {
-		return strings.TrimLeft(arg, "-"), ""
+		return strings.TrimLeft(arg, "-"), "", false
 	}
 }
 
@@ -198,33 +964,198 @@ func (ap *argParser) extractComponents(arg string) (string, string) {
 // result's position in the semantic stack.  This function returns an error if
 // the flag is set multiple times.
 func (ap *argParser) setFlag(ndx int, flag *Flag) error {
+	if _, ok := ap.semanticStack[ndx].negated[flag.name]; ok {
+		return kindErrorf(KindConflict, "flag `%s` cannot be given together with `--no-%s`", flag.name, flag.name)
+	}
+
 	if _, ok := ap.semanticStack[ndx].flags[flag.name]; ok {
-		return fmt.Errorf("flag `%s` set multiple times", flag.name)
+		if flag.countable {
+			ap.semanticStack[ndx].counts[flag.name]++
+			return nil
+		}
+
+		if flag.allowRepeat {
+			return nil
+		}
+
+		return kindErrorf(KindDuplicate, "flag `%s` set multiple times", flag.name)
 	}
 
 	ap.semanticStack[ndx].flags[flag.name] = struct{}{}
 
+	if flag.countable {
+		ap.setCount(ndx, flag, 1)
+	}
+
 	if flag.action != nil {
 		flag.action()
 	}
 
+	if flag.cmdAction != nil {
+		flag.cmdAction(ap)
+	}
+
+	return nil
+}
+
+// negateFlag records that a negatable flag was negated.  The input index is the
+// result's position in the semantic stack.
+func (ap *argParser) negateFlag(ndx int, flag *Flag) error {
+	res := ap.semanticStack[ndx]
+	if _, ok := res.flags[flag.name]; ok {
+		return kindErrorf(KindConflict, "flag `%s` cannot be given together with `--no-%s`", flag.name, flag.name)
+	}
+
+	if _, ok := res.negated[flag.name]; ok && !flag.allowRepeat {
+		return kindErrorf(KindDuplicate, "flag `no-%s` set multiple times", flag.name)
+	}
+
+	if res.negated == nil {
+		res.negated = make(map[string]struct{})
+	}
+
+	res.negated[flag.name] = struct{}{}
 	return nil
 }
 
+// setCombinedFlags sets each of the flags whose single character short names
+// are combined in the given name.  It returns false without setting any flags
+// if any of the characters is not the short name of a flag.
+func (ap *argParser) setCombinedFlags(names string) (bool, error) {
+	if utf8.RuneCountInString(names) < 2 {
+		return false, nil
+	}
+
+	var ndxs []int
+	var flags []*Flag
+	for _, r := range names {
+		ndx, flag, ok := ap.lookupFlag(string(r), true)
+		if !ok {
+			return false, nil
+		}
+
+		ndxs = append(ndxs, ndx)
+		flags = append(flags, flag)
+	}
+
+	for i, flag := range flags {
+		if err := ap.setFlag(ndxs[i], flag); err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
+
+// setFlagCount sets the count of a countable flag to a count given explicitly
+// as its value.  The input index is the result's position in the semantic
+// stack.
+func (ap *argParser) setFlagCount(ndx int, flag *Flag, value string) error {
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 1 {
+		return kindErrorf(KindInvalidValue, "count of flag `%s` must be a positive integer, got `%s`", flag.name, value)
+	}
+
+	if _, ok := ap.semanticStack[ndx].flags[flag.name]; !ok {
+		if err := ap.setFlag(ndx, flag); err != nil {
+			return err
+		}
+	}
+
+	ap.setCount(ndx, flag, count)
+	return nil
+}
+
+// setCount sets the count of a countable flag in a result on the stack
+func (ap *argParser) setCount(ndx int, flag *Flag, count int) {
+	res := ap.semanticStack[ndx]
+	if res.counts == nil {
+		res.counts = make(map[string]int)
+	}
+
+	res.counts[flag.name] = count
+}
+
 // setArg attempts to set the value for an argument in the parse result.
 // The input index is the result's position in the semantic stack.
 func (ap *argParser) setArg(ndx int, arg Argument, value string) error {
-	if _, ok := ap.semanticStack[ndx].Arguments[arg.Name()]; ok {
-		return fmt.Errorf("argument `%s` set multiple times", arg.Name())
+	prev, given := ap.semanticStack[ndx].Arguments[arg.Name()]
+	acc, isList := arg.(listArgument)
+	if given && !isList {
+		return kindErrorf(KindDuplicate, "argument `%s` set multiple times", arg.Name())
 	}
 
 	val, err := arg.checkValue(value)
 	if err == nil {
-		ap.semanticStack[ndx].Arguments[arg.Name()] = val
+		// repeated lists accumulate their elements
+		if given {
+			val = acc.appendValue(prev, val)
+		}
+
+		ap.storeValue(ndx, arg, value, val)
+		ap.markProvided(ndx, arg)
 		return nil
 	}
 
-	return err
+	return kindErrorOf(KindInvalidValue, err)
+}
+
+// markProvided records that the value of an argument of the command at the
+// given position on the stack was provided by the user
+func (ap *argParser) markProvided(ndx int, arg Argument) {
+	res := ap.semanticStack[ndx]
+	if res.provided == nil {
+		res.provided = make(map[string]struct{})
+	}
+
+	res.provided[arg.Name()] = struct{}{}
+}
+
+// storeValue stores the value of an argument in the result of the command at
+// the given position on the stack.  If the argument has lazy validation, the
+// value is also recorded so that it can be validated later.
+func (ap *argParser) storeValue(ndx int, arg Argument, raw string, val interface{}) {
+	res := ap.semanticStack[ndx]
+	res.Arguments[arg.Name()] = val
+
+	if arg.base().lazyValidation {
+		if res.lazyValues == nil {
+			res.lazyValues = make(map[string]lazyValue)
+		}
+
+		res.lazyValues[arg.Name()] = lazyValue{arg: arg, raw: raw, value: val}
+	}
+
+	if sa, ok := arg.(*StringArgument); ok && sa.codec != nil {
+		if res.rawValues == nil {
+			res.rawValues = make(map[string]string)
+		}
+
+		res.rawValues[arg.Name()] = raw
+	}
+}
+
+// exit exits the application unless the parser is lenient in which case it
+// halts parsing instead
+func (ap *argParser) exit(code int) {
+	if ap.lenient {
+		ap.halted = true
+		ap.result.halted = true
+	} else {
+		ap.initialCommand.exit(code)
+	}
+}
+
+// newResult creates an empty result for a command on the command stack
+func (ap *argParser) newResult() *ArgParseResult {
+	if ap.pool != nil {
+		return ap.pool.getResult()
+	}
+
+	return &ArgParseResult{
+		flags:     make(map[string]struct{}),
+		Arguments: make(map[string]interface{}),
+	}
 }
 
 // currCommand returns the command on top of the command stack
@@ -0,0 +1,103 @@
+package olive
+
+import "sync"
+
+// ParserPool parses arguments against a CLI while reusing the parser state and
+// results of previous parses.  It is intended for applications which parse many
+// sets of arguments against the same CLI: results obtained from the pool should
+// be returned to it using `Release` once they are no longer needed.  A parser
+// pool is safe for concurrent use.
+type ParserPool struct {
+	cli *Command
+
+	parsers sync.Pool
+	results sync.Pool
+}
+
+// NewParserPool creates a new parser pool for the given CLI
+func NewParserPool(cli *Command) *ParserPool {
+	pp := &ParserPool{cli: cli}
+
+	pp.parsers.New = func() interface{} {
+		return &argParser{initialCommand: cli, pool: pp}
+	}
+
+	pp.results.New = func() interface{} {
+		return &ArgParseResult{
+			flags:     make(map[string]struct{}),
+			Arguments: make(map[string]interface{}),
+		}
+	}
+
+	return pp
+}
+
+// ParseArgs parses the slice of arguments provided like the global `ParseArgs`
+// function except that the result is drawn from the pool
+func (pp *ParserPool) ParseArgs(args []string) (*ArgParseResult, error) {
+	ap := pp.parsers.Get().(*argParser)
+	defer pp.parsers.Put(ap)
+
+	result, err := ap.parseArgs(args)
+
+	// the result stacks should not keep the results alive for the pool
+	for i := range ap.semanticStack {
+		ap.semanticStack[i] = nil
+	}
+
+	// the partial results of a failed parse are never returned so they can be
+	// reused immediately
+	if err != nil {
+		pp.Release(ap.result)
+	}
+	ap.result = nil
+
+	return result, err
+}
+
+// Release returns a result obtained from the pool and the results of all its
+// subcommands to the pool.  Neither the result nor any values obtained from it
+// such as its trailing arguments may be used after it is released.
+func (pp *ParserPool) Release(res *ArgParseResult) {
+	for res != nil {
+		next := res.subcommandRes
+
+		for name := range res.flags {
+			delete(res.flags, name)
+		}
+
+		for name := range res.counts {
+			delete(res.counts, name)
+		}
+
+		for name := range res.negated {
+			delete(res.negated, name)
+		}
+
+		for name := range res.Arguments {
+			delete(res.Arguments, name)
+		}
+
+		res.command = nil
+		res.parent = nil
+		res.subcommandName = ""
+		res.subcommandRes = nil
+		res.primaryArg = ""
+		res.primaryArgs = res.primaryArgs[:0]
+		res.halted = false
+		res.invokedName = ""
+		res.rawValues = nil
+		res.trailingArgs = res.trailingArgs[:0]
+		res.missingRequired = nil
+		res.provided = nil
+		res.lazyValues = nil
+
+		pp.results.Put(res)
+		res = next
+	}
+}
+
+// getResult retrieves an empty result from the pool
+func (pp *ParserPool) getResult() *ArgParseResult {
+	return pp.results.Get().(*ArgParseResult)
+}
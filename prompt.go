@@ -0,0 +1,118 @@
+package olive
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Prompter is used to interactively request the value of a required argument
+// that was not supplied on the command line.
+type Prompter interface {
+	// Prompt asks for the value of the given argument and returns the raw
+	// string that was entered
+	Prompt(arg Argument) (string, error)
+}
+
+// ReaderPrompter is a prompter which writes its prompts to a writer and reads
+// the responses line by line from a reader
+type ReaderPrompter struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// NewReaderPrompter creates a new prompter reading from `r` and writing its
+// prompts to `w`
+func NewReaderPrompter(r io.Reader, w io.Writer) *ReaderPrompter {
+	return &ReaderPrompter{r: bufio.NewReader(r), w: w}
+}
+
+// Prompt writes a prompt for the argument and reads a single line of input
+func (rp *ReaderPrompter) Prompt(arg Argument) (string, error) {
+	if arg.Description() == "" {
+		fmt.Fprintf(rp.w, "%s: ", arg.Name())
+	} else {
+		fmt.Fprintf(rp.w, "%s (%s): ", arg.Name(), arg.Description())
+	}
+
+	line, err := rp.r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// -----------------------------------------------------------------------------
+
+// promptForMissing prompts for the values of all required arguments that have
+// not received a value.  If no prompter is available, this does nothing.
+func (ap *argParser) promptForMissing() error {
+	// collect the missing arguments in a consistent order regardless of map
+	// ordering so that the prompts are always presented in the same order
+	var missing []Argument
+	var missingNdxs []int
+	for i, c := range ap.commandStack {
+		names := make([]string, 0, len(c.args))
+		for name := range c.args {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if ap.isMissing(i, c.args[name]) {
+				missing = append(missing, c.args[name])
+				missingNdxs = append(missingNdxs, i)
+			}
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	p := ap.initialCommand.Prompter
+	if p == nil {
+		if !stdinIsTerminal() {
+			ap.initialCommand.warnf("cannot prompt for missing arguments: standard input is not a terminal")
+			return nil
+		}
+
+		p = NewReaderPrompter(os.Stdin, os.Stdout)
+	}
+
+	for i, arg := range missing {
+		raw, err := p.Prompt(arg)
+		if err != nil {
+			return fmt.Errorf("failed to read value for argument `%s`: %s", arg.Name(), err.Error())
+		}
+
+		val, err := arg.checkValue(raw)
+		if err != nil {
+			return err
+		}
+
+		ap.storeValue(missingNdxs[i], arg, raw, val)
+		ap.markProvided(missingNdxs[i], arg)
+	}
+
+	return nil
+}
+
+// stdinIsTerminal checks whether standard input is connected to a terminal
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+
+	// the null device is a character device but is never interactive
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(fi, null) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,163 @@
+package olive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Dispatch parses the arguments against a CLI and invokes the handler for the
+// most specific subcommand that was selected.  The handlers are keyed by the
+// path of subcommands leading to the selected command joined by spaces (eg.
+// `mod init`).  The handler for the initial command is keyed by "".
+func Dispatch(cli *Command, args []string, handlers map[string]func(*ArgParseResult) error) error {
+	result, err := ParseArgs(cli, args)
+	if err != nil {
+		return err
+	}
+
+	path, res := selectedCommand(result)
+
+	if handler, ok := handlers[strings.Join(path, " ")]; ok {
+		return handler(res)
+	}
+
+	return fmt.Errorf("no handler for command `%s`", strings.Join(append([]string{cli.Name}, path...), " "))
+}
+
+// selectedCommand walks a parse result down to its most specific subcommand.
+// It returns the names of the subcommands leading to it and its result.
+func selectedCommand(result *ArgParseResult) ([]string, *ArgParseResult) {
+	var path []string
+
+	for {
+		name, subres, ok := result.Subcommand()
+		if !ok {
+			return path, result
+		}
+
+		path = append(path, name)
+		result = subres
+	}
+}
+
+// SetHandler sets the function run by `Run` and `Execute` when this command is
+// the most specific subcommand selected.  The handler is passed the context of
+// the run which it should respect if it runs for a long time.
+func (c *Command) SetHandler(handler func(context.Context, *ArgParseResult) error) {
+	c.handler = handler
+}
+
+// Run parses the arguments against the CLI and runs the handler of the most
+// specific subcommand selected with its result.  Unlike `Execute`, it never
+// exits the application: parse errors and errors returned by the handler are
+// returned instead.  The handler is passed a background context.
+func (c *Command) Run(args []string) error {
+	return c.RunContext(context.Background(), args)
+}
+
+// RunContext runs the CLI like `Run` passing the given context to the handler
+// so that it can be cancelled.  The handler is not run at all if the context is
+// already done once the arguments have been parsed.
+func (c *Command) RunContext(ctx context.Context, args []string) error {
+	result, err := ParseArgs(c, args)
+	if err != nil {
+		return err
+	}
+
+	return runHandler(ctx, result)
+}
+
+// Execute parses the arguments of the application (`os.Args`) against the CLI
+// and runs the handler of the most specific subcommand selected.  It then exits
+// the application following the usual conventions: if the arguments could not
+// be parsed, the error and the usage of the command are written to the error
+// writer and the application exits with `ParseErrorExitCode`.  If the handler
+// returns an error, it is written to the error writer and the application exits
+// with `RunErrorExitCode`.  Otherwise, the application exits with 0.
+func (c *Command) Execute() {
+	c.execute(func(_ *argParser, result *ArgParseResult) error {
+		return runHandler(context.Background(), result)
+	})
+}
+
+// RunMain parses the arguments of the application (`os.Args`) against the CLI
+// and calls `fn` with the result.  It is a convenience for CLIs without
+// subcommands and handles errors and exits the application just like
+// `Execute`.
+func (c *Command) RunMain(fn func(*ArgParseResult) error) {
+	c.execute(func(_ *argParser, result *ArgParseResult) error {
+		return fn(result)
+	})
+}
+
+// execute parses the arguments of the application and runs the given function
+// on success, exiting the application with the appropriate exit code
+func (c *Command) execute(run func(*argParser, *ArgParseResult) error) {
+	ap := &argParser{initialCommand: c}
+
+	result, err := ap.parseArgs(os.Args)
+	if err != nil {
+		if c.JSONErrors {
+			c.writeJSONError(err)
+		} else {
+			fmt.Fprintf(c.ErrorWriter, "error: %s\n\n%s:\n\n", err.Error(), c.helpText().Usage)
+			fmt.Fprint(c.ErrorWriter, getUsageLine(ap.currCommand(), ap.currResult()))
+		}
+
+		c.exit(c.ParseErrorExitCode)
+		return
+	}
+
+	if err := run(ap, result); err != nil {
+		if c.JSONErrors {
+			c.writeJSONError(err)
+		} else {
+			fmt.Fprintf(c.ErrorWriter, "error: %s\n", err.Error())
+		}
+
+		c.exit(c.RunErrorExitCode)
+		return
+	}
+
+	c.exit(0)
+}
+
+// writeJSONError writes an error to the error writer as a JSON object on a
+// single line.  Errors which cannot encode themselves as JSON are written as an
+// object containing only their message.
+func (c *Command) writeJSONError(err error) {
+	var m json.Marshaler
+	if errors.As(err, &m) {
+		if data, merr := m.MarshalJSON(); merr == nil {
+			fmt.Fprintln(c.ErrorWriter, string(data))
+			return
+		}
+	}
+
+	data, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+
+	fmt.Fprintln(c.ErrorWriter, string(data))
+}
+
+// runHandler runs the handler of the most specific subcommand selected in the
+// result with the given context
+func runHandler(ctx context.Context, result *ArgParseResult) error {
+	path, res := selectedCommand(result)
+
+	c := res.Command()
+	if c.handler == nil {
+		return fmt.Errorf("no handler for command `%s`", strings.Join(append([]string{c.root().Name}, path...), " "))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return c.handler(ctx, res)
+}